@@ -0,0 +1,715 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dannyxd11/AoC2019/intcode"
+	log "github.com/sirupsen/logrus"
+)
+
+// The server speaks the Debug Adapter Protocol over stdin and stdout. It has a
+// single thread whose single stack frame is the instruction at ip.
+
+const (
+	dapThread = 1
+	dapFrame  = 1
+
+	// Variable references for the scopes.
+	registersRef = 1
+	memoryRef    = 2
+	inputRef     = 3
+	outputRef    = 4
+
+	// listingRef is the source reference of the disassembly shown for
+	// programs that were not loaded from assembly source.
+	listingRef = 1
+
+	// dapBatch is how many instructions run between checks for a pause.
+	dapBatch = 10000
+)
+
+type dapRequest struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type dapResponse struct {
+	Seq        int         `json:"seq"`
+	Type       string      `json:"type"`
+	RequestSeq int         `json:"request_seq"`
+	Success    bool        `json:"success"`
+	Command    string      `json:"command"`
+	Message    string      `json:"message,omitempty"`
+	Body       interface{} `json:"body,omitempty"`
+}
+
+type dapEvent struct {
+	Seq   int         `json:"seq"`
+	Type  string      `json:"type"`
+	Event string      `json:"event"`
+	Body  interface{} `json:"body,omitempty"`
+}
+
+type dapSource struct {
+	Name            string `json:"name"`
+	Path            string `json:"path,omitempty"`
+	SourceReference int    `json:"sourceReference,omitempty"`
+}
+
+type dapBreakpoint struct {
+	ID          int    `json:"id,omitempty"`
+	Verified    bool   `json:"verified"`
+	Line        int    `json:"line,omitempty"`
+	Message     string `json:"message,omitempty"`
+	Instruction string `json:"instructionReference,omitempty"`
+}
+
+type dapVariable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	VariablesReference int    `json:"variablesReference"`
+	IndexedVariables   int    `json:"indexedVariables,omitempty"`
+	MemoryReference    string `json:"memoryReference,omitempty"`
+}
+
+// program is the code being debugged and how its addresses map onto the lines
+// of the source shown for it.
+type program struct {
+	code   []int
+	entry  int
+	source dapSource
+	// listing is the disassembly shown when there is no source file.
+	listing string
+	// lines maps source lines to the address of their code, and addrs the
+	// other way.
+	lines map[int]int
+	addrs map[int]int
+}
+
+func loadDebugProgram(path string) (*program, error) {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &program{addrs: map[int]int{}}
+	if strings.HasSuffix(path, ".asm") {
+		p.code, p.lines, err = intcode.Standard.AssembleSource(string(text))
+		if err != nil {
+			return nil, err
+		}
+		p.source = dapSource{Name: filepath.Base(path), Path: path}
+	} else {
+		img, err := intcode.Load(strings.NewReader(string(text)))
+		if err != nil {
+			return nil, err
+		}
+		p.code, p.entry = img.Program, img.Entry
+		p.listing, p.lines = listing(p.code)
+		p.source = dapSource{Name: filepath.Base(path) + " (disassembly)", SourceReference: listingRef}
+	}
+	for line, addr := range p.lines {
+		p.addrs[addr] = line
+	}
+	return p, nil
+}
+
+// listing disassembles code one instruction per line.
+func listing(code []int) (string, map[int]int) {
+	var b strings.Builder
+	lines := map[int]int{}
+	for addr, line := 0, 1; addr < len(code); line++ {
+		text, size := intcode.Standard.Disassemble(code, addr)
+		fmt.Fprintf(&b, "%6d: %s\n", addr, text)
+		lines[line] = addr
+		addr += size
+	}
+	return b.String(), lines
+}
+
+// lineFor returns the first line at or after line that holds code.
+func (p *program) lineFor(line int) (int, int, bool) {
+	best := -1
+	for l := range p.lines {
+		if l >= line && (best < 0 || l < best) {
+			best = l
+		}
+	}
+	if best < 0 {
+		return 0, 0, false
+	}
+	return best, p.lines[best], true
+}
+
+type dapServer struct {
+	w    io.Writer
+	seq  int
+	reqs chan *dapRequest
+
+	prog    *program
+	m       *intcode.Machine
+	input   *intcode.Queue
+	output  *intcode.Queue
+	shown   int
+	running bool
+
+	// Execution starts once the program is launched and the client has said
+	// its configuration is done, whichever comes last.
+	configured  bool
+	started     bool
+	stopOnEntry bool
+
+	// breakLines are the source lines breakpoints were asked for, kept so
+	// they can be resolved when the program is launched after them.
+	breakLines        []int
+	lineBreaks        map[int]bool
+	instructionBreaks map[int]bool
+}
+
+func (s *dapServer) send(msg interface{}) {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := fmt.Fprintf(s.w, "Content-Length: %d\r\n\r\n%s", len(b), b); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (s *dapServer) nextSeq() int {
+	s.seq++
+	return s.seq
+}
+
+func (s *dapServer) respond(req *dapRequest, body interface{}) {
+	s.send(dapResponse{s.nextSeq(), "response", req.Seq, true, req.Command, "", body})
+}
+
+func (s *dapServer) fail(req *dapRequest, err error) {
+	s.send(dapResponse{s.nextSeq(), "response", req.Seq, false, req.Command, err.Error(), nil})
+}
+
+func (s *dapServer) event(name string, body interface{}) {
+	s.send(dapEvent{s.nextSeq(), "event", name, body})
+}
+
+func (s *dapServer) stopped(reason string, description string) {
+	s.running = false
+	s.event("stopped", map[string]interface{}{
+		"reason":            reason,
+		"description":       description,
+		"threadId":          dapThread,
+		"allThreadsStopped": true,
+	})
+}
+
+// readRequests decodes requests from r until it closes.
+func readRequests(r io.Reader, reqs chan<- *dapRequest) {
+	defer close(reqs)
+	tp := textproto.NewReader(bufio.NewReader(r))
+	for {
+		header, err := tp.ReadMIMEHeader()
+		if err != nil {
+			if err != io.EOF {
+				log.Error(err)
+			}
+			return
+		}
+		length, err := strconv.Atoi(header.Get("Content-Length"))
+		if err != nil {
+			log.Errorf("Bad Content-Length: %v", err)
+			return
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(tp.R, body); err != nil {
+			log.Error(err)
+			return
+		}
+		req := &dapRequest{}
+		if err := json.Unmarshal(body, req); err != nil {
+			log.Errorf("Bad request: %v", err)
+			continue
+		}
+		reqs <- req
+	}
+}
+
+// serve handles requests until the client disconnects. While the program is
+// running it executes a batch of instructions between requests, so a pause
+// can interrupt it.
+func (s *dapServer) serve() {
+	for {
+		var req *dapRequest
+		var ok bool
+		if s.running {
+			select {
+			case req, ok = <-s.reqs:
+			default:
+				s.run(dapBatch)
+				continue
+			}
+		} else {
+			req, ok = <-s.reqs
+		}
+		if !ok || !s.handle(req) {
+			return
+		}
+	}
+}
+
+func (s *dapServer) handle(req *dapRequest) bool {
+	var err error
+	switch req.Command {
+	case "initialize":
+		s.respond(req, map[string]interface{}{
+			"supportsConfigurationDoneRequest": true,
+			"supportsInstructionBreakpoints":   true,
+			"supportsDisassembleRequest":       true,
+			"supportsSteppingGranularity":      true,
+			"supportsEvaluateForHovers":        true,
+		})
+		s.event("initialized", nil)
+	case "launch":
+		err = s.launch(req)
+	case "setBreakpoints":
+		err = s.setBreakpoints(req)
+	case "setInstructionBreakpoints":
+		err = s.setInstructionBreakpoints(req)
+	case "setExceptionBreakpoints":
+		s.respond(req, map[string]interface{}{"breakpoints": []dapBreakpoint{}})
+	case "configurationDone":
+		s.respond(req, nil)
+		s.configured = true
+		s.start()
+	case "threads":
+		s.respond(req, map[string]interface{}{
+			"threads": []map[string]interface{}{{"id": dapThread, "name": "intcode"}},
+		})
+	case "stackTrace":
+		err = s.stackTrace(req)
+	case "scopes":
+		s.respond(req, map[string]interface{}{"scopes": s.scopes()})
+	case "variables":
+		err = s.variables(req)
+	case "source":
+		if s.prog == nil || s.prog.listing == "" {
+			err = errors.New("no disassembly to show")
+			break
+		}
+		s.respond(req, map[string]interface{}{"content": s.prog.listing})
+	case "disassemble":
+		err = s.disassemble(req)
+	case "evaluate":
+		err = s.evaluate(req)
+	case "continue":
+		if err = s.requireMachine(); err == nil {
+			s.respond(req, map[string]interface{}{"allThreadsContinued": true})
+			s.running = true
+			// Step off a breakpoint we are stopped on.
+			s.run(1)
+		}
+	case "next", "stepIn", "stepOut":
+		if err = s.requireMachine(); err == nil {
+			s.respond(req, nil)
+			if s.stepOne() {
+				s.stopped("step", "")
+			}
+		}
+	case "pause":
+		s.respond(req, nil)
+		if s.running {
+			s.stopped("pause", "")
+		}
+	case "disconnect", "terminate":
+		s.respond(req, nil)
+		return false
+	default:
+		err = fmt.Errorf("unsupported request %s", req.Command)
+	}
+	if err != nil {
+		s.fail(req, err)
+	}
+	return true
+}
+
+func (s *dapServer) requireMachine() error {
+	if s.m == nil {
+		return errors.New("no program has been launched")
+	}
+	return nil
+}
+
+func (s *dapServer) launch(req *dapRequest) error {
+	var args struct {
+		Program     string `json:"program"`
+		Inputs      []int  `json:"inputs"`
+		StopOnEntry bool   `json:"stopOnEntry"`
+		Engine      string `json:"engine"`
+	}
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		return err
+	}
+	engine := intcode.Interpreted
+	if args.Engine != "" {
+		var err error
+		if engine, err = lookupEngine(args.Engine); err != nil {
+			return err
+		}
+	}
+	prog, err := loadDebugProgram(args.Program)
+	if err != nil {
+		return err
+	}
+	s.prog = prog
+	s.m = intcode.NewFromImage(intcode.Image{Program: prog.code, Entry: prog.entry})
+	s.m.SetEngine(engine)
+	s.input, s.output = intcode.NewQueue(args.Inputs...), &intcode.Queue{}
+	s.m.Input, s.m.Output = s.input, s.output
+	s.stopOnEntry = args.StopOnEntry
+	s.respond(req, nil)
+
+	// Breakpoints set before the launch can only now be placed.
+	if len(s.breakLines) > 0 {
+		for _, bp := range s.resolveBreakpoints() {
+			s.event("breakpoint", map[string]interface{}{"reason": "changed", "breakpoint": bp})
+		}
+	}
+	s.start()
+	return nil
+}
+
+// start begins execution once there is a machine and configuration is done.
+func (s *dapServer) start() {
+	if s.m == nil || !s.configured || s.started {
+		return
+	}
+	s.started = true
+	if s.stopOnEntry {
+		s.stopped("entry", "")
+	} else {
+		s.running = true
+	}
+}
+
+func (s *dapServer) setBreakpoints(req *dapRequest) error {
+	var args struct {
+		Source      dapSource `json:"source"`
+		Breakpoints []struct {
+			Line int `json:"line"`
+		} `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		return err
+	}
+	s.breakLines = nil
+	for _, bp := range args.Breakpoints {
+		s.breakLines = append(s.breakLines, bp.Line)
+	}
+	if s.prog == nil {
+		result := []dapBreakpoint{}
+		for i, line := range s.breakLines {
+			result = append(result, dapBreakpoint{ID: i + 1, Line: line, Message: "waiting for the program to be launched"})
+		}
+		s.respond(req, map[string]interface{}{"breakpoints": result})
+		return nil
+	}
+	s.respond(req, map[string]interface{}{"breakpoints": s.resolveBreakpoints()})
+	return nil
+}
+
+// resolveBreakpoints places the breakpoints on breakLines at the code of the
+// loaded program.
+func (s *dapServer) resolveBreakpoints() []dapBreakpoint {
+	s.lineBreaks = map[int]bool{}
+	result := []dapBreakpoint{}
+	for i, requested := range s.breakLines {
+		line, addr, ok := s.prog.lineFor(requested)
+		if !ok {
+			result = append(result, dapBreakpoint{ID: i + 1, Message: "no code at or after this line"})
+			continue
+		}
+		s.lineBreaks[addr] = true
+		result = append(result, dapBreakpoint{ID: i + 1, Verified: true, Line: line, Instruction: strconv.Itoa(addr)})
+	}
+	return result
+}
+
+func (s *dapServer) setInstructionBreakpoints(req *dapRequest) error {
+	var args struct {
+		Breakpoints []struct {
+			InstructionReference string `json:"instructionReference"`
+			Offset               int    `json:"offset"`
+		} `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		return err
+	}
+	s.instructionBreaks = map[int]bool{}
+	result := []dapBreakpoint{}
+	for _, bp := range args.Breakpoints {
+		addr, err := strconv.Atoi(bp.InstructionReference)
+		if err != nil {
+			result = append(result, dapBreakpoint{Message: "not an address"})
+			continue
+		}
+		addr += bp.Offset
+		s.instructionBreaks[addr] = true
+		result = append(result, dapBreakpoint{Verified: true, Instruction: strconv.Itoa(addr)})
+	}
+	s.respond(req, map[string]interface{}{"breakpoints": result})
+	return nil
+}
+
+// stepOne executes an instruction and reports whether the machine can carry
+// on, sending the events for it otherwise.
+func (s *dapServer) stepOne() bool {
+	err := s.m.Step()
+	for ; s.shown < s.output.Len(); s.shown++ {
+		s.event("output", map[string]interface{}{
+			"category": "stdout",
+			"output":   fmt.Sprintf("%d\n", s.output.Values()[s.shown]),
+		})
+	}
+	switch {
+	case errors.Is(err, intcode.ErrHalted) || s.m.Status() == intcode.Halted:
+		s.running = false
+		s.event("exited", map[string]interface{}{"exitCode": 0})
+		s.event("terminated", nil)
+		return false
+	case err != nil:
+		s.event("output", map[string]interface{}{"category": "stderr", "output": err.Error() + "\n"})
+		s.stopped("exception", err.Error())
+		return false
+	case s.m.Status() == intcode.WaitingInput:
+		s.stopped("pause", "waiting for input, evaluate \"input <values>\" to provide it")
+		return false
+	}
+	return true
+}
+
+// run executes up to n instructions, stopping at a breakpoint.
+func (s *dapServer) run(n int) {
+	for i := 0; i < n && s.running; i++ {
+		if !s.stepOne() {
+			return
+		}
+		if ip := s.m.Ip(); s.lineBreaks[ip] || s.instructionBreaks[ip] {
+			s.stopped("breakpoint", "")
+			return
+		}
+	}
+}
+
+func (s *dapServer) stackTrace(req *dapRequest) error {
+	if err := s.requireMachine(); err != nil {
+		return err
+	}
+	text, _ := s.m.InstructionSet().Disassemble(s.m.Memory(), s.m.Ip())
+	frame := map[string]interface{}{
+		"id":                          dapFrame,
+		"name":                        fmt.Sprintf("%d: %s", s.m.Ip(), text),
+		"column":                      0,
+		"line":                        0,
+		"instructionPointerReference": strconv.Itoa(s.m.Ip()),
+	}
+	if line, ok := s.prog.addrs[s.m.Ip()]; ok {
+		frame["source"], frame["line"], frame["column"] = s.prog.source, line, 1
+	}
+	s.respond(req, map[string]interface{}{"stackFrames": []interface{}{frame}, "totalFrames": 1})
+	return nil
+}
+
+func (s *dapServer) scopes() []map[string]interface{} {
+	scope := func(name string, ref int, indexed int) map[string]interface{} {
+		sc := map[string]interface{}{"name": name, "variablesReference": ref, "expensive": false}
+		if indexed > 0 {
+			sc["indexedVariables"] = indexed
+		}
+		return sc
+	}
+	if s.m == nil {
+		return nil
+	}
+	return []map[string]interface{}{
+		scope("Registers", registersRef, 0),
+		scope("Memory", memoryRef, len(s.m.Memory())),
+		scope("Input", inputRef, s.input.Len()),
+		scope("Output", outputRef, s.output.Len()),
+	}
+}
+
+func (s *dapServer) variables(req *dapRequest) error {
+	var args struct {
+		VariablesReference int `json:"variablesReference"`
+		Start              int `json:"start"`
+		Count              int `json:"count"`
+	}
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		return err
+	}
+	if err := s.requireMachine(); err != nil {
+		return err
+	}
+
+	vars := []dapVariable{}
+	list := func(name func(int) string, values []int) {
+		end := len(values)
+		if args.Count > 0 && args.Start+args.Count < end {
+			end = args.Start + args.Count
+		}
+		for i := args.Start; i < end; i++ {
+			vars = append(vars, dapVariable{Name: name(i), Value: strconv.Itoa(values[i])})
+		}
+	}
+	index := func(i int) string { return strconv.Itoa(i) }
+
+	switch args.VariablesReference {
+	case registersRef:
+		vars = append(vars,
+			dapVariable{Name: "ip", Value: strconv.Itoa(s.m.Ip()), MemoryReference: strconv.Itoa(s.m.Ip())},
+			dapVariable{Name: "bp", Value: strconv.Itoa(s.m.RelativeBase())},
+			dapVariable{Name: "status", Value: s.m.Status().String()},
+			dapVariable{Name: "steps", Value: strconv.Itoa(s.m.Steps())},
+			dapVariable{Name: "instruction", Value: s.m.Explain()},
+		)
+	case memoryRef:
+		list(func(i int) string { return fmt.Sprintf("[%d]", i) }, s.m.Memory())
+	case inputRef:
+		list(index, s.input.Values())
+	case outputRef:
+		list(index, s.output.Values())
+	default:
+		return fmt.Errorf("unknown variables reference %d", args.VariablesReference)
+	}
+	s.respond(req, map[string]interface{}{"variables": vars})
+	return nil
+}
+
+func (s *dapServer) disassemble(req *dapRequest) error {
+	var args struct {
+		MemoryReference   string `json:"memoryReference"`
+		InstructionOffset int    `json:"instructionOffset"`
+		InstructionCount  int    `json:"instructionCount"`
+	}
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		return err
+	}
+	if err := s.requireMachine(); err != nil {
+		return err
+	}
+	start, err := strconv.Atoi(args.MemoryReference)
+	if err != nil {
+		return fmt.Errorf("bad memory reference %q", args.MemoryReference)
+	}
+
+	// Instructions vary in length, so walk the code from the start to find
+	// the ones either side of the reference.
+	memory := s.m.Memory()
+	var addrs []int
+	for addr := 0; addr < len(memory); {
+		addrs = append(addrs, addr)
+		_, size := s.m.InstructionSet().Disassemble(memory, addr)
+		addr += size
+	}
+	first := sort.SearchInts(addrs, start) + args.InstructionOffset
+
+	instructions := []map[string]interface{}{}
+	for i := first; i < first+args.InstructionCount; i++ {
+		if i < 0 || i >= len(addrs) {
+			instructions = append(instructions, map[string]interface{}{"address": "-1", "instruction": "", "presentationHint": "invalid"})
+			continue
+		}
+		text, size := s.m.InstructionSet().Disassemble(memory, addrs[i])
+		ins := map[string]interface{}{
+			"address":          strconv.Itoa(addrs[i]),
+			"instruction":      text,
+			"instructionBytes": fmt.Sprint(memory[addrs[i]:min(addrs[i]+size, len(memory))]),
+		}
+		if line, ok := s.prog.addrs[addrs[i]]; ok {
+			ins["location"], ins["line"] = s.prog.source, line
+		}
+		instructions = append(instructions, ins)
+	}
+	s.respond(req, map[string]interface{}{"instructions": instructions})
+	return nil
+}
+
+// evaluate handles expressions typed into the debug console or hovered over:
+// ip, bp, [addr], @offset, a bare address, or "input v..." to queue input.
+func (s *dapServer) evaluate(req *dapRequest) error {
+	var args struct {
+		Expression string `json:"expression"`
+	}
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		return err
+	}
+	if err := s.requireMachine(); err != nil {
+		return err
+	}
+
+	expr := strings.TrimSpace(args.Expression)
+	var result string
+	switch {
+	case expr == "ip":
+		result = strconv.Itoa(s.m.Ip())
+	case expr == "bp":
+		result = strconv.Itoa(s.m.RelativeBase())
+	case strings.HasPrefix(expr, "input"):
+		values, err := intcode.ReadProgram(strings.NewReader(strings.TrimPrefix(expr, "input")))
+		if err != nil {
+			return err
+		}
+		s.input.Push(values...)
+		result = fmt.Sprint(s.input.Values())
+	default:
+		addr, err := s.address(expr)
+		if err != nil {
+			return err
+		}
+		val, err := s.m.Peek(addr)
+		if err != nil {
+			return err
+		}
+		result = strconv.Itoa(val)
+	}
+	s.respond(req, map[string]interface{}{"result": result, "variablesReference": 0})
+	return nil
+}
+
+// address resolves an operand written as [n], @n or n to an address.
+func (s *dapServer) address(expr string) (int, error) {
+	offset := 0
+	if strings.HasPrefix(expr, "@") {
+		offset, expr = s.m.RelativeBase(), expr[1:]
+	} else if strings.HasPrefix(expr, "[") && strings.HasSuffix(expr, "]") {
+		expr = expr[1 : len(expr)-1]
+	}
+	addr, err := strconv.Atoi(expr)
+	if err != nil {
+		return 0, fmt.Errorf("cannot evaluate %q", expr)
+	}
+	return addr + offset, nil
+}
+
+func dap(args []string) {
+	if len(args) != 0 {
+		log.Fatal("dap takes no arguments, the program is given in the launch request")
+	}
+	// stdout carries the protocol, so keep logging out of it.
+	log.SetOutput(os.Stderr)
+
+	s := &dapServer{w: os.Stdout, reqs: make(chan *dapRequest)}
+	go readRequests(os.Stdin, s.reqs)
+	s.serve()
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// dapClient drives a dapServer over a pipe as an editor would.
+type dapClient struct {
+	t   *testing.T
+	w   io.WriteCloser
+	r   *textproto.Reader
+	seq int
+}
+
+func newDAPClient(t *testing.T) *dapClient {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	s := &dapServer{w: outW, reqs: make(chan *dapRequest)}
+	go readRequests(inR, s.reqs)
+	go func() {
+		s.serve()
+		outW.Close()
+	}()
+	c := &dapClient{t: t, w: inW, r: textproto.NewReader(bufio.NewReader(outR))}
+	t.Cleanup(func() { inW.Close() })
+	return c
+}
+
+func (c *dapClient) request(command string, args interface{}) {
+	c.seq++
+	b, err := json.Marshal(map[string]interface{}{"seq": c.seq, "type": "request", "command": command, "arguments": args})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(b), b); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// expect reads the next message and checks it is the named event or
+// successful response.
+func (c *dapClient) expect(kind, name string) map[string]interface{} {
+	c.t.Helper()
+	msg, raw := c.next()
+	got := msg["event"]
+	if kind == "response" {
+		got = msg["command"]
+		if msg["success"] != true {
+			c.t.Fatalf("%s failed: %v", name, msg["message"])
+		}
+	}
+	if msg["type"] != kind || got != name {
+		c.t.Fatalf("got %s, want %s %s", raw, kind, name)
+	}
+	body, _ := msg["body"].(map[string]interface{})
+	return body
+}
+
+// expectFailure reads the next message, checks it is a failed response to
+// command and returns its message.
+func (c *dapClient) expectFailure(command string) string {
+	c.t.Helper()
+	msg, raw := c.next()
+	if msg["type"] != "response" || msg["command"] != command || msg["success"] != false {
+		c.t.Fatalf("got %s, want %s to fail", raw, command)
+	}
+	message, _ := msg["message"].(string)
+	return message
+}
+
+func (c *dapClient) next() (map[string]interface{}, []byte) {
+	c.t.Helper()
+	header, err := c.r.ReadMIMEHeader()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	raw := make([]byte, length)
+	if _, err := io.ReadFull(c.r.R, raw); err != nil {
+		c.t.Fatal(err)
+	}
+	msg := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		c.t.Fatal(err)
+	}
+	return msg, raw
+}
+
+func TestDAPSession(t *testing.T) {
+	// Lines of the disassembly: 1 is the add at 0, 2 the output at 4.
+	path := filepath.Join(t.TempDir(), "add.txt")
+	if err := os.WriteFile(path, []byte("1101,1,2,9,4,9,99,0,0,0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := newDAPClient(t)
+
+	c.request("initialize", map[string]interface{}{"adapterID": "intcode"})
+	c.expect("response", "initialize")
+	c.expect("event", "initialized")
+
+	// Editors set breakpoints as soon as they are initialized, which may be
+	// before the launch.
+	c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]interface{}{"sourceReference": listingRef},
+		"breakpoints": []map[string]int{{"line": 2}},
+	})
+	body := c.expect("response", "setBreakpoints")
+	if bp := body["breakpoints"].([]interface{})[0].(map[string]interface{}); bp["verified"] != false {
+		t.Errorf("breakpoint before launch = %v, want it unverified", bp)
+	}
+
+	// A bad launch fails the request rather than the adapter.
+	c.request("launch", map[string]interface{}{"program": path, "engine": "jit"})
+	if msg := c.expectFailure("launch"); !strings.Contains(msg, "jit") {
+		t.Errorf("launch with an unknown engine failed with %q", msg)
+	}
+
+	c.request("launch", map[string]interface{}{"program": path})
+	c.expect("response", "launch")
+	body = c.expect("event", "breakpoint")
+	if bp := body["breakpoint"].(map[string]interface{}); bp["verified"] != true || bp["instructionReference"] != "4" {
+		t.Errorf("breakpoint after launch = %v, want it verified at 4", bp)
+	}
+
+	// Nothing runs until configuration is done.
+	c.request("configurationDone", nil)
+	c.expect("response", "configurationDone")
+	body = c.expect("event", "stopped")
+	if body["reason"] != "breakpoint" {
+		t.Errorf("stopped for %v, want breakpoint", body["reason"])
+	}
+
+	c.request("evaluate", map[string]interface{}{"expression": "[9]"})
+	if body = c.expect("response", "evaluate"); body["result"] != "3" {
+		t.Errorf("[9] = %v, want 3", body["result"])
+	}
+
+	c.request("continue", map[string]interface{}{"threadId": dapThread})
+	c.expect("response", "continue")
+	if body = c.expect("event", "output"); body["output"] != "3\n" {
+		t.Errorf("output = %q, want 3", body["output"])
+	}
+	c.expect("event", "exited")
+	c.expect("event", "terminated")
+
+	c.request("disconnect", nil)
+	c.expect("response", "disconnect")
+}
@@ -0,0 +1,350 @@
+// Command intcode runs Intcode programs outside of the day folders.
+//
+// Usage:
+//
+//	intcode run [-ascii] [-engine name] [-lenient] [-record session] [-trace] program
+//	intcode replay [-engine name] [-lenient] [-force] session program
+//	intcode convert [-binary] [-checksum] [-name name] [-entry ip] in out
+//	intcode optimize [-verify inputs] in out
+//	intcode analyse program
+//	intcode repl [program]
+//	intcode watch [-inputs list] [-width n] [-steps n] [-delay d] [-html file] program
+//	intcode dap
+//	intcode serve [-addr host:port] [-steps n] [-memory cells] [-timeout d] [-programs n] [-runs n] [-concurrent n] [-ttl d]
+//
+// Programs may be in the comma separated text format or the binary image
+// format; convert translates between the two.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dannyxd11/AoC2019/intcode"
+	log "github.com/sirupsen/logrus"
+)
+
+var commands = map[string]func(args []string){
+	"run":      run,
+	"replay":   replay,
+	"convert":  convert,
+	"optimize": optimize,
+	"analyse":  analyse,
+	"repl":     replCommand,
+	"watch":    watch,
+	"dap":      dap,
+	"serve":    serve,
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: intcode <command> [arguments]")
+	fmt.Fprintln(os.Stderr, "commands:")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintln(os.Stderr, "\t"+name)
+	}
+	os.Exit(2)
+}
+
+// lookupEngine returns the engine called name.
+func lookupEngine(name string) (intcode.Engine, error) {
+	for _, e := range intcode.Engines {
+		if e.String() == name {
+			return e, nil
+		}
+	}
+	return 0, fmt.Errorf("unrecognised engine %s", name)
+}
+
+func engineFor(name string) intcode.Engine {
+	e, err := lookupEngine(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return e
+}
+
+func loadProgram(path string) intcode.Image {
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	img, err := intcode.Load(file)
+	if err != nil {
+		log.Fatalf("%s: %v", path, err)
+	}
+	return img
+}
+
+// numberInput reads one integer per line, prompting for each when stdin is
+// used interactively.
+type numberInput struct {
+	r      *bufio.Reader
+	prompt io.Writer
+}
+
+func (n numberInput) Read() (int, error) {
+	for {
+		if n.prompt != nil {
+			fmt.Fprint(n.prompt, "> ")
+		}
+		line, err := n.r.ReadString('\n')
+		if len(line) == 0 && err != nil {
+			return 0, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		val, err := strconv.Atoi(line)
+		if err != nil {
+			return 0, fmt.Errorf("input %q: %w", line, err)
+		}
+		return val, nil
+	}
+}
+
+type numberOutput struct {
+	w io.Writer
+}
+
+func (n numberOutput) Write(val int) error {
+	_, err := fmt.Fprintln(n.w, val)
+	return err
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func run(args []string) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	ascii := fs.Bool("ascii", false, "exchange text with the program as ASCII codes")
+	engine := fs.String("engine", intcode.Interpreted.String(), "execution engine: interpreted or compiled")
+	lenient := fs.Bool("lenient", false, "treat immediate mode write parameters as positions instead of failing")
+	record := fs.String("record", "", "file to record the inputs and outputs of the run to, for replay")
+	trace := fs.Bool("trace", false, "print each instruction to stderr as it is executed")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("run needs exactly one program file")
+	}
+
+	m := intcode.NewFromImage(loadProgram(fs.Arg(0)))
+	m.SetEngine(engineFor(*engine))
+	if *lenient {
+		m.WriteMode = intcode.LenientWrites
+	}
+	if *ascii {
+		m.Input, m.Output = intcode.NewASCIIInput(os.Stdin), intcode.NewASCIIOutput(os.Stdout)
+	} else {
+		in := numberInput{r: bufio.NewReader(os.Stdin)}
+		if isTerminal(os.Stdin) {
+			in.prompt = os.Stderr
+		}
+		m.Input, m.Output = in, numberOutput{os.Stdout}
+	}
+	if *trace {
+		m.Observe(&intcode.Hooks{Before: func(m *intcode.Machine) {
+			fmt.Fprintln(os.Stderr, m.Explain())
+		}})
+	}
+	var rec *intcode.Recorder
+	if *record != "" {
+		rec = intcode.Record(m)
+	}
+
+	err := m.Run()
+	if rec != nil {
+		// Save the session even if the run failed, so the failure can be
+		// reproduced.
+		if serr := writeSession(*record, rec.Session()); serr != nil {
+			log.Error(serr)
+		}
+	}
+	if errors.Is(err, io.EOF) {
+		log.Fatal("Program is waiting for input but stdin is closed")
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	log.WithField("Steps", m.Steps()).Debug("Program halted")
+}
+
+// writeFile creates path and fills it with write. The file is closed however
+// write fares, and an error closing it is returned if write succeeded.
+func writeFile(path string, write func(w io.Writer) error) (err error) {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	return write(out)
+}
+
+func writeSession(path string, s *intcode.Session) error {
+	return writeFile(path, func(w io.Writer) error {
+		return intcode.WriteSession(w, s)
+	})
+}
+
+func replay(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	engine := fs.String("engine", intcode.Interpreted.String(), "execution engine: interpreted or compiled")
+	lenient := fs.Bool("lenient", false, "treat immediate mode write parameters as positions instead of failing")
+	force := fs.Bool("force", false, "replay even if the program differs from the one recorded")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		log.Fatal("replay needs a session and a program file")
+	}
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	session, err := intcode.ReadSession(file)
+	file.Close()
+	if err != nil {
+		log.Fatalf("%s: %v", fs.Arg(0), err)
+	}
+
+	m := intcode.NewFromImage(loadProgram(fs.Arg(1)))
+	m.SetEngine(engineFor(*engine))
+	if *lenient {
+		m.WriteMode = intcode.LenientWrites
+	}
+	if *force {
+		session.Checksum = intcode.ProgramChecksum(m.Memory())
+	}
+	if err := intcode.Replay(m, session); err != nil {
+		log.Fatal(err)
+	}
+	log.WithFields(log.Fields{"Events": len(session.Events), "Steps": m.Steps()}).Info("Replay matched the session")
+}
+
+func convert(args []string) {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	toBinary := fs.Bool("binary", false, "write a binary image instead of text")
+	checksum := fs.Bool("checksum", false, "add a checksum to the binary image")
+	name := fs.String("name", "", "name to store in the binary image")
+	entry := fs.Int("entry", -1, "entry point to store in the binary image, defaults to the input's")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		log.Fatal("convert needs an input and an output file")
+	}
+
+	img := loadProgram(fs.Arg(0))
+	if *name != "" {
+		img.Name = *name
+	}
+	if *entry >= 0 {
+		img.Entry = *entry
+	}
+	img.Checksum = *checksum
+
+	err := writeFile(fs.Arg(1), func(w io.Writer) error {
+		if *toBinary {
+			return intcode.WriteImage(w, img)
+		}
+		if img.Name != "" || img.Entry != 0 {
+			log.WithFields(log.Fields{"Name": img.Name, "Entry": img.Entry}).Warn("Text format cannot hold the image metadata, dropping it")
+		}
+		return intcode.WriteProgram(w, img.Program)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parseInputs reads a comma separated list of inputs for a program.
+func parseInputs(s string) []int {
+	if s == "" {
+		return nil
+	}
+	inputs, err := intcode.ReadProgram(strings.NewReader(s))
+	if err != nil {
+		log.Fatalf("Bad inputs: %v", err)
+	}
+	return inputs
+}
+
+func optimize(args []string) {
+	fs := flag.NewFlagSet("optimize", flag.ExitOnError)
+	var verify []string
+	fs.Func("verify", "comma separated inputs to check the optimized program against the original with, may be repeated", func(s string) error {
+		verify = append(verify, s)
+		return nil
+	})
+	maxSteps := fs.Int("steps", 10000000, "instructions to run each program for when verifying")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		log.Fatal("optimize needs an input and an output file")
+	}
+
+	img := loadProgram(fs.Arg(0))
+	if img.Entry != 0 {
+		log.Fatal("Only programs that start at address 0 can be optimized")
+	}
+	optimized, rewrites := intcode.Optimize(img.Program)
+	for _, r := range rewrites {
+		log.Info(r)
+	}
+	log.WithFields(log.Fields{"Rewrites": len(rewrites), "Before": len(img.Program), "After": len(optimized)}).Info("Optimized")
+
+	for _, s := range verify {
+		err := intcode.Verify(img.Program, optimized, rewrites, parseInputs(s), *maxSteps)
+		if errors.Is(err, intcode.ErrInconclusive) {
+			log.WithField("Inputs", s).Warnf("Not verified: %v", err)
+			continue
+		} else if err != nil {
+			log.Fatalf("Inputs %s: %v", s, err)
+		}
+		log.WithField("Inputs", s).Info("Verified")
+	}
+
+	err := writeFile(fs.Arg(1), func(w io.Writer) error {
+		return intcode.WriteProgram(w, optimized)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func analyse(args []string) {
+	if len(args) != 1 {
+		log.Fatal("analyse needs exactly one program file")
+	}
+	img := loadProgram(args[0])
+	if img.Entry != 0 {
+		log.Fatal("Only programs that start at address 0 can be analysed")
+	}
+	fmt.Print(intcode.Analyse(img.Program))
+}
+
+func main() {
+	log.SetLevel(log.InfoLevel)
+	if len(os.Args) < 2 {
+		usage()
+	}
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+	}
+	command(os.Args[2:])
+}
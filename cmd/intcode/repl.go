@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/dannyxd11/AoC2019/intcode"
+	log "github.com/sirupsen/logrus"
+)
+
+const replHelp = `Type an instruction to place it at ip and run it, e.g.
+  add #1 #2 [10]     mnemonic form: #n immediate, [n] position, @n relative
+  1101,1,2,10        raw form, which may hold several instructions
+Commands:
+  :regs              show ip, relative base and status
+  :mem [addr [n]]    show n cells from addr
+  :set addr val      store val at addr
+  :ip addr           move ip to addr
+  :bp val            set the relative base
+  :in val...         queue input values
+  :out               show every output so far
+  :dis [addr [n]]    disassemble n instructions from addr
+  :run               run from ip until the machine stops
+  :step [n]          run n instructions, explaining each
+  :load file         replace the machine with a program
+  :reset             start again with an empty machine
+  :help              show this help
+  :quit              leave
+`
+
+// replMaxSteps stops a typed program that never leaves the code it was given.
+const replMaxSteps = 100000
+
+type repl struct {
+	m      *intcode.Machine
+	input  *intcode.Queue
+	output *intcode.Queue
+	out    io.Writer
+	shown  int // outputs already printed
+	// trace makes step describe each instruction and the memory it changes.
+	trace bool
+}
+
+func newRepl(program []int, out io.Writer) *repl {
+	r := &repl{out: out}
+	r.reset(program)
+	return r
+}
+
+func (r *repl) reset(program []int) {
+	r.m = intcode.New(program)
+	r.input, r.output = &intcode.Queue{}, &intcode.Queue{}
+	r.m.Input, r.m.Output = r.input, r.output
+	r.shown = 0
+}
+
+func (r *repl) printf(format string, args ...interface{}) {
+	fmt.Fprintf(r.out, format, args...)
+}
+
+func (r *repl) regs() {
+	r.printf("ip %d  bp %d  %v  steps %d  input %v\n", r.m.Ip(), r.m.RelativeBase(), r.m.Status(), r.m.Steps(), r.input.Values())
+}
+
+func (r *repl) mem(start, n int) {
+	for addr := start; addr < start+n; addr++ {
+		val, err := r.m.Peek(addr)
+		if err != nil {
+			r.printf("%6d: %v\n", addr, err)
+			continue
+		}
+		r.printf("%6d: %d\n", addr, val)
+	}
+}
+
+func (r *repl) dis(start, n int) {
+	memory := r.m.Memory()
+	for addr, i := start, 0; i < n && addr < len(memory); i++ {
+		text, size := r.m.InstructionSet().Disassemble(memory, addr)
+		r.printf("%6d: %s\n", addr, text)
+		addr += size
+	}
+}
+
+// step runs one instruction, printing any output it produces.
+func (r *repl) step() error {
+	if r.trace {
+		r.printf("  %s\n", r.m.Explain())
+	}
+	var before []int
+	if r.trace {
+		before = r.m.Memory()
+	}
+	if err := r.m.Step(); err != nil {
+		return err
+	}
+	if r.trace {
+		for addr, val := range r.m.Memory() {
+			if (addr < len(before) && before[addr] != val) || (addr >= len(before) && val != 0) {
+				r.printf("    [%d] = %d\n", addr, val)
+			}
+		}
+	}
+	for ; r.shown < r.output.Len(); r.shown++ {
+		r.printf("    output %d\n", r.output.Values()[r.shown])
+	}
+	return nil
+}
+
+// exec places code at ip and runs it until execution leaves it.
+func (r *repl) exec(code []int) error {
+	start := r.m.Ip()
+	if r.m.Status() == intcode.Halted {
+		start = len(r.m.Memory())
+	}
+	for i, v := range code {
+		if err := r.m.Poke(start+i, v); err != nil {
+			return err
+		}
+	}
+	// The machine only moves to addresses inside memory, so make sure the
+	// cell after the code exists for execution to fall through to.
+	next, err := r.m.Peek(start + len(code))
+	if err != nil {
+		return err
+	}
+	if err := r.m.Poke(start+len(code), next); err != nil {
+		return err
+	}
+	if err := r.m.SetIp(start); err != nil {
+		return err
+	}
+	for steps := 0; r.m.Ip() >= start && r.m.Ip() < start+len(code); steps++ {
+		if steps == replMaxSteps {
+			return fmt.Errorf("stopped after %d steps", steps)
+		}
+		if err := r.step(); err != nil {
+			return err
+		}
+		if r.m.Status() != intcode.Running {
+			break
+		}
+	}
+	return nil
+}
+
+// ints parses the arguments of a command, filling in defaults for any that
+// are missing.
+func ints(args []string, defaults ...int) ([]int, error) {
+	vals := append([]int(nil), defaults...)
+	for i, a := range args {
+		v, err := strconv.Atoi(a)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", a)
+		}
+		if i < len(vals) {
+			vals[i] = v
+		} else {
+			vals = append(vals, v)
+		}
+	}
+	return vals, nil
+}
+
+var errQuit = errors.New("quit")
+
+func (r *repl) command(line string) error {
+	fields := strings.Fields(line)
+	name, args := fields[0], fields[1:]
+	switch name {
+	case ":help":
+		r.printf("%s", replHelp)
+	case ":quit", ":q":
+		return errQuit
+	case ":regs":
+		r.regs()
+	case ":mem":
+		v, err := ints(args, r.m.Ip(), 8)
+		if err != nil {
+			return err
+		}
+		r.mem(v[0], v[1])
+	case ":dis":
+		v, err := ints(args, r.m.Ip(), 8)
+		if err != nil {
+			return err
+		}
+		r.dis(v[0], v[1])
+	case ":set":
+		v, err := ints(args)
+		if err != nil || len(v) != 2 {
+			return fmt.Errorf("usage: :set addr val")
+		}
+		return r.m.Poke(v[0], v[1])
+	case ":ip":
+		v, err := ints(args)
+		if err != nil || len(v) != 1 {
+			return fmt.Errorf("usage: :ip addr")
+		}
+		return r.m.SetIp(v[0])
+	case ":bp":
+		v, err := ints(args)
+		if err != nil || len(v) != 1 {
+			return fmt.Errorf("usage: :bp val")
+		}
+		r.m.SetRelativeBase(v[0])
+	case ":in":
+		v, err := ints(args)
+		if err != nil {
+			return err
+		}
+		r.input.Push(v...)
+	case ":out":
+		r.printf("%v\n", r.output.Values())
+	case ":run":
+		for steps := 0; r.m.Status() == intcode.Running || steps == 0; steps++ {
+			if steps == replMaxSteps {
+				return fmt.Errorf("stopped after %d steps", steps)
+			}
+			if err := r.step(); err != nil {
+				return err
+			}
+		}
+		r.regs()
+	case ":step":
+		v, err := ints(args, 1)
+		if err != nil {
+			return err
+		}
+		r.trace = true
+		defer func() { r.trace = false }()
+		for i := 0; i < v[0] && r.m.Status() != intcode.Halted; i++ {
+			if err := r.step(); err != nil {
+				return err
+			}
+		}
+		r.regs()
+	case ":load":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: :load file")
+		}
+		file, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		img, err := intcode.Load(file)
+		if err != nil {
+			return err
+		}
+		r.reset(img.Program)
+		return r.m.SetIp(img.Entry)
+	case ":reset":
+		r.reset(nil)
+	default:
+		return fmt.Errorf("unknown command %s, try :help", name)
+	}
+	return nil
+}
+
+func (r *repl) eval(line string) error {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil
+	}
+	if strings.HasPrefix(line, ":") {
+		return r.command(line)
+	}
+
+	var code []int
+	var err error
+	if c := line[0]; c == '-' || (c >= '0' && c <= '9') {
+		code, err = intcode.ReadProgram(strings.NewReader(line))
+	} else {
+		code, err = r.m.InstructionSet().Assemble(line)
+	}
+	if err != nil {
+		return err
+	}
+	r.trace = true
+	defer func() { r.trace = false }()
+	if err := r.exec(code); err != nil {
+		return err
+	}
+	r.regs()
+	return nil
+}
+
+func replCommand(args []string) {
+	fs := flag.NewFlagSet("repl", flag.ExitOnError)
+	fs.Parse(args)
+
+	var program []int
+	if fs.NArg() == 1 {
+		program = loadProgram(fs.Arg(0)).Program
+	}
+	r := newRepl(program, os.Stdout)
+	interactive := isTerminal(os.Stdin)
+	if interactive {
+		r.printf("Intcode REPL, :help for help\n")
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		if interactive {
+			r.printf("intcode> ")
+		}
+		if !scanner.Scan() {
+			break
+		}
+		if err := r.eval(scanner.Text()); err == errQuit {
+			break
+		} else if err != nil {
+			r.printf("error: %v\n", err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+}
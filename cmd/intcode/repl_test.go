@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRepl(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		program []int
+		// lines are evaluated in turn; only the output of the last is checked.
+		lines []string
+		want  string
+		err   string
+	}{
+		{
+			name:  "raw",
+			lines: []string{"1101,1,2,10"},
+			want:  "  0: add #1 #2 -> [10]\n    [10] = 3\nip 4  bp 0  running  steps 1  input []\n",
+		},
+		{
+			// The same instruction typed as a mnemonic.
+			name:  "mnemonic",
+			lines: []string{"add #1 #2 [10]"},
+			want:  "  0: add #1 #2 -> [10]\n    [10] = 3\nip 4  bp 0  running  steps 1  input []\n",
+		},
+		{
+			name:    "set",
+			program: []int{99, 0, 0},
+			lines:   []string{":set 2 7", ":mem 1 2"},
+			want:    "     1: 0\n     2: 7\n",
+		},
+		{
+			// Output 5, then add it to itself.
+			name:    "step",
+			program: []int{104, 5, 1, 1, 1, 9, 99, 0, 0, 0},
+			lines:   []string{":step 2"},
+			want: "  0: out #5\n    output 5\n  2: add [1]=5 [1]=5 -> [9]\n    [9] = 10\n" +
+				"ip 6  bp 0  running  steps 2  input []\n",
+		},
+		{
+			name:    "run forever",
+			program: []int{1105, 1, 0},
+			lines:   []string{":run"},
+			err:     fmt.Sprintf("stopped after %d steps", replMaxSteps),
+		},
+		{
+			name:    "reset",
+			program: []int{104, 5, 99},
+			lines:   []string{":in 1", ":run", ":reset", ":regs"},
+			want:    "ip 0  bp 0  running  steps 0  input []\n",
+		},
+	} {
+		var buf bytes.Buffer
+		r := newRepl(c.program, &buf)
+		var err error
+		for i, line := range c.lines {
+			if i == len(c.lines)-1 {
+				buf.Reset()
+				err = r.eval(line)
+			} else if err := r.eval(line); err != nil {
+				t.Fatalf("%s: %s: %v", c.name, line, err)
+			}
+		}
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%s: err = %v, want one containing %q", c.name, err, c.err)
+			}
+		} else if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		}
+		if buf.String() != c.want {
+			t.Errorf("%s: printed\n%s\nwant\n%s", c.name, buf.String(), c.want)
+		}
+	}
+}
@@ -0,0 +1,587 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dannyxd11/AoC2019/intcode"
+	log "github.com/sirupsen/logrus"
+)
+
+// The server keeps uploaded programs and their runs in memory. Its endpoints
+// are:
+//
+//	POST /programs                upload a program in either format
+//	GET  /programs                list the uploaded programs
+//	GET  /programs/{id}           fetch a program
+//	POST /programs/{id}/runs      start a run, see runRequest
+//	GET  /runs/{id}               the run's status and output so far
+//	GET  /runs/{id}/memory        the run's memory
+//	GET  /runs/{id}/output        stream the output as server-sent events
+//	POST /runs/{id}/input         queue more input for a waiting run
+//
+// Programs and runs that have not been asked about for the TTL are forgotten,
+// except for runs still executing.
+
+const maxUpload = 16 << 20
+
+// serverLimits bound what the server and a single run may use. A run may ask
+// for less than steps, memory and timeout.
+type serverLimits struct {
+	steps  int
+	memory int
+	// timeout is how long a run may spend executing, not counting the time
+	// it waits for input.
+	timeout time.Duration
+
+	programs   int
+	runs       int
+	concurrent int
+	ttl        time.Duration
+}
+
+type storedProgram struct {
+	ID      string `json:"id"`
+	Name    string `json:"name,omitempty"`
+	Entry   int    `json:"entry"`
+	Size    int    `json:"size"`
+	Program []int  `json:"program,omitempty"`
+
+	// used is when the program was last asked about, guarded by the
+	// server's mu.
+	used time.Time
+}
+
+// runRequest is the body of a request to start a run. Zero limits mean the
+// server's.
+type runRequest struct {
+	Inputs      []int  `json:"inputs"`
+	MaxSteps    int    `json:"maxSteps"`
+	MemoryLimit int    `json:"memoryLimit"`
+	Timeout     string `json:"timeout"`
+	Engine      string `json:"engine"`
+	Lenient     bool   `json:"lenient"`
+}
+
+// A run's state is its machine's status, or one of these once it has stopped
+// for another reason.
+const (
+	stateFailed    = "failed"
+	stateStepLimit = "step limit reached"
+	stateTimedOut  = "timed out"
+)
+
+type runStatus struct {
+	ID      string `json:"id"`
+	Program string `json:"program"`
+	State   string `json:"state"`
+	Done    bool   `json:"done"`
+	Ip      int    `json:"ip"`
+	Bp      int    `json:"bp"`
+	Steps   int    `json:"steps"`
+	Output  []int  `json:"output"`
+	Error   string `json:"error,omitempty"`
+}
+
+// serverRun is a program being run. Its fields are guarded by mu, which the
+// goroutine executing it holds for each step, apart from used, which the
+// server's mu guards.
+type serverRun struct {
+	mu       sync.Mutex
+	id       string
+	program  string
+	m        *intcode.Machine
+	input    *intcode.Queue
+	output   []int
+	state    string
+	err      error
+	maxSteps int
+	// remaining is how much longer the run may spend executing.
+	remaining time.Duration
+	// slots is the server's, from which the run took a token to execute.
+	slots chan struct{}
+	used  time.Time
+	// changed is closed and replaced whenever there is new output or the
+	// state changes, to wake up anyone streaming the run.
+	changed chan struct{}
+}
+
+// Write collects the run's output. It is called by the machine while mu is
+// held.
+func (r *serverRun) Write(val int) error {
+	r.output = append(r.output, val)
+	r.notify()
+	return nil
+}
+
+func (r *serverRun) notify() {
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// done reports whether the run has stopped for good. A run waiting for input
+// is not done, as more may be posted to it.
+func (r *serverRun) done() bool {
+	return r.state != intcode.Running.String() && r.state != intcode.WaitingInput.String()
+}
+
+func (r *serverRun) status() runStatus {
+	s := runStatus{
+		ID:      r.id,
+		Program: r.program,
+		State:   r.state,
+		Done:    r.done(),
+		Ip:      r.m.Ip(),
+		Bp:      r.m.RelativeBase(),
+		Steps:   r.m.Steps(),
+		Output:  append([]int{}, r.output...),
+	}
+	if r.err != nil {
+		s.Error = r.err.Error()
+	}
+	return s
+}
+
+// execute steps the machine until it stops, runs out of steps or time, or
+// needs input it has not been given. It gives back the run's slot when it
+// returns, and only the time spent here counts towards the run's timeout.
+func (r *serverRun) execute() {
+	defer func() { <-r.slots }()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	start := time.Now()
+	defer func() { r.remaining -= time.Since(start) }()
+	r.state = intcode.Running.String()
+	for {
+		if r.m.Steps() >= r.maxSteps {
+			r.state = stateStepLimit
+			break
+		}
+		// Checking the clock costs more than a step, so only do it now and
+		// then.
+		if r.m.Steps()%1000 == 0 && time.Since(start) > r.remaining {
+			r.state = stateTimedOut
+			break
+		}
+		if err := r.m.Step(); err != nil {
+			r.state, r.err = stateFailed, err
+			break
+		}
+		if r.m.Status() != intcode.Running {
+			r.state = r.m.Status().String()
+			break
+		}
+		// Let readers in between steps.
+		if r.m.Steps()%1000 == 0 {
+			r.mu.Unlock()
+			r.mu.Lock()
+		}
+	}
+	r.notify()
+	log.WithFields(log.Fields{"Run": r.id, "State": r.state, "Steps": r.m.Steps()}).Info("Run stopped")
+}
+
+type server struct {
+	limits serverLimits
+	// slots holds a token for each run executing.
+	slots chan struct{}
+	now   func() time.Time
+
+	mu       sync.Mutex
+	programs map[string]*storedProgram
+	runs     map[string]*serverRun
+	// lastProgram and lastRun are the most recent IDs given out.
+	lastProgram int
+	lastRun     int
+}
+
+func newServer(limits serverLimits) *server {
+	return &server{
+		limits:   limits,
+		slots:    make(chan struct{}, limits.concurrent),
+		now:      time.Now,
+		programs: map[string]*storedProgram{},
+		runs:     map[string]*serverRun{},
+	}
+}
+
+// evict forgets the programs and runs that have not been used for the TTL,
+// apart from runs that are executing. s.mu must be held.
+func (s *server) evict() {
+	now := s.now()
+	for id, p := range s.programs {
+		if now.Sub(p.used) > s.limits.ttl {
+			delete(s.programs, id)
+			log.WithField("Program", id).Info("Program evicted")
+		}
+	}
+	for id, run := range s.runs {
+		run.mu.Lock()
+		executing := run.state == intcode.Running.String()
+		run.mu.Unlock()
+		if !executing && now.Sub(run.used) > s.limits.ttl {
+			delete(s.runs, id)
+			log.WithField("Run", id).Info("Run evicted")
+		}
+	}
+}
+
+// acquire takes a slot for a run to execute in, reporting false if every one
+// is taken.
+func (s *server) acquire() bool {
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// errBusy is returned when as many runs as are allowed are executing.
+var errBusy = errors.New("too many runs are executing, try again later")
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /programs", s.uploadProgram)
+	mux.HandleFunc("GET /programs", s.listPrograms)
+	mux.HandleFunc("GET /programs/{id}", s.getProgram)
+	mux.HandleFunc("POST /programs/{id}/runs", s.startRun)
+	mux.HandleFunc("GET /runs/{id}", s.getRun)
+	mux.HandleFunc("GET /runs/{id}/memory", s.getMemory)
+	mux.HandleFunc("GET /runs/{id}/output", s.streamOutput)
+	mux.HandleFunc("POST /runs/{id}/input", s.postInput)
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error(err)
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+func (s *server) uploadProgram(w http.ResponseWriter, r *http.Request) {
+	img, err := intcode.Load(http.MaxBytesReader(w, r.Body, maxUpload))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(img.Program) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("program is empty"))
+		return
+	}
+	name := img.Name
+	if q := r.URL.Query().Get("name"); q != "" {
+		name = q
+	}
+
+	s.mu.Lock()
+	s.evict()
+	if len(s.programs) >= s.limits.programs {
+		s.mu.Unlock()
+		writeError(w, http.StatusServiceUnavailable, fmt.Errorf("the server is holding the most programs it may, %d", s.limits.programs))
+		return
+	}
+	s.lastProgram++
+	p := &storedProgram{
+		ID:      strconv.Itoa(s.lastProgram),
+		Name:    name,
+		Entry:   img.Entry,
+		Size:    len(img.Program),
+		Program: img.Program,
+		used:    s.now(),
+	}
+	s.programs[p.ID] = p
+	s.mu.Unlock()
+
+	log.WithFields(log.Fields{"Program": p.ID, "Name": p.Name, "Size": p.Size}).Info("Program uploaded")
+	writeJSON(w, http.StatusCreated, p)
+}
+
+func (s *server) listPrograms(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evict()
+	list := []storedProgram{}
+	for _, p := range s.programs {
+		summary := *p
+		summary.Program = nil
+		list = append(list, summary)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, _ := strconv.Atoi(list[i].ID)
+		b, _ := strconv.Atoi(list[j].ID)
+		return a < b
+	})
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *server) lookupProgram(w http.ResponseWriter, r *http.Request) *storedProgram {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evict()
+	p, ok := s.programs[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no program %s", r.PathValue("id")))
+		return nil
+	}
+	p.used = s.now()
+	return p
+}
+
+func (s *server) lookupRun(w http.ResponseWriter, r *http.Request) *serverRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evict()
+	run, ok := s.runs[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no run %s", r.PathValue("id")))
+		return nil
+	}
+	run.used = s.now()
+	return run
+}
+
+func (s *server) getProgram(w http.ResponseWriter, r *http.Request) {
+	if p := s.lookupProgram(w, r); p != nil {
+		writeJSON(w, http.StatusOK, p)
+	}
+}
+
+// limit returns requested if it is set and within max, and max otherwise.
+func limit(requested, max int) int {
+	if requested <= 0 || requested > max {
+		return max
+	}
+	return requested
+}
+
+func (s *server) startRun(w http.ResponseWriter, r *http.Request) {
+	p := s.lookupProgram(w, r)
+	if p == nil {
+		return
+	}
+	var req runRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxUpload)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	timeout := s.limits.timeout
+	if req.Timeout != "" {
+		d, err := time.ParseDuration(req.Timeout)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		timeout = time.Duration(limit(int(d), int(timeout)))
+	}
+	engine := intcode.Interpreted
+	if req.Engine != "" {
+		var err error
+		if engine, err = lookupEngine(req.Engine); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	run := &serverRun{
+		program:   p.ID,
+		m:         intcode.NewFromImage(intcode.Image{Program: p.Program, Entry: p.Entry}),
+		input:     intcode.NewQueue(req.Inputs...),
+		state:     intcode.Running.String(),
+		maxSteps:  limit(req.MaxSteps, s.limits.steps),
+		remaining: timeout,
+		slots:     s.slots,
+		changed:   make(chan struct{}),
+	}
+	run.m.SetEngine(engine)
+	run.m.MemoryLimit = limit(req.MemoryLimit, s.limits.memory)
+	if req.Lenient {
+		run.m.WriteMode = intcode.LenientWrites
+	}
+	run.m.Input, run.m.Output = run.input, run
+
+	s.mu.Lock()
+	s.evict()
+	if len(s.runs) >= s.limits.runs {
+		s.mu.Unlock()
+		writeError(w, http.StatusServiceUnavailable, fmt.Errorf("the server is holding the most runs it may, %d", s.limits.runs))
+		return
+	}
+	if !s.acquire() {
+		s.mu.Unlock()
+		writeError(w, http.StatusServiceUnavailable, errBusy)
+		return
+	}
+	s.lastRun++
+	run.id = strconv.Itoa(s.lastRun)
+	run.used = s.now()
+	s.runs[run.id] = run
+	s.mu.Unlock()
+
+	log.WithFields(log.Fields{"Run": run.id, "Program": p.ID, "Inputs": len(req.Inputs)}).Info("Run started")
+	go run.execute()
+
+	w.Header().Set("Location", "/runs/"+run.id)
+	run.mu.Lock()
+	status := run.status()
+	run.mu.Unlock()
+	writeJSON(w, http.StatusCreated, status)
+}
+
+func (s *server) getRun(w http.ResponseWriter, r *http.Request) {
+	run := s.lookupRun(w, r)
+	if run == nil {
+		return
+	}
+	run.mu.Lock()
+	status := run.status()
+	run.mu.Unlock()
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (s *server) getMemory(w http.ResponseWriter, r *http.Request) {
+	run := s.lookupRun(w, r)
+	if run == nil {
+		return
+	}
+	run.mu.Lock()
+	memory := run.m.Memory()
+	run.mu.Unlock()
+	writeJSON(w, http.StatusOK, memory)
+}
+
+func (s *server) postInput(w http.ResponseWriter, r *http.Request) {
+	run := s.lookupRun(w, r)
+	if run == nil {
+		return
+	}
+	var req struct {
+		Inputs []int `json:"inputs"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxUpload)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	if run.done() {
+		writeError(w, http.StatusConflict, fmt.Errorf("run %s has stopped: %s", run.id, run.state))
+		return
+	}
+	waiting := run.state == intcode.WaitingInput.String()
+	if waiting && !s.acquire() {
+		writeError(w, http.StatusServiceUnavailable, errBusy)
+		return
+	}
+	run.input.Push(req.Inputs...)
+	if waiting {
+		// Claim the run before unlocking, so that a second post does not
+		// start it twice.
+		run.state = intcode.Running.String()
+		go run.execute()
+	}
+	writeJSON(w, http.StatusAccepted, run.status())
+}
+
+// streamOutput sends each output value as an event with its index as the id,
+// so a client that reconnects with Last-Event-ID carries on where it left
+// off. A final "state" event carries the run's status once it stops.
+func (s *server) streamOutput(w http.ResponseWriter, r *http.Request) {
+	run := s.lookupRun(w, r)
+	if run == nil {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+	next := 0
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		if n, err := strconv.Atoi(id); err == nil {
+			next = n + 1
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	for {
+		run.mu.Lock()
+		output, state, changed := run.output[min(next, len(run.output)):], run.state, run.changed
+		var status runStatus
+		if run.done() || state == intcode.WaitingInput.String() {
+			status = run.status()
+		}
+		run.mu.Unlock()
+
+		for _, val := range output {
+			fmt.Fprintf(w, "id: %d\nevent: output\ndata: %d\n\n", next, val)
+			next++
+		}
+		if status.ID != "" {
+			b, err := json.Marshal(status)
+			if err != nil {
+				log.Error(err)
+				return
+			}
+			fmt.Fprintf(w, "event: state\ndata: %s\n\n", b)
+		}
+		flusher.Flush()
+		if status.Done {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func serve(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8019", "address to listen on")
+	steps := fs.Int("steps", 100000000, "most instructions a run may execute")
+	memory := fs.Int("memory", intcode.DefaultMemoryLimit, "most memory cells a run may use")
+	timeout := fs.Duration("timeout", time.Minute, "longest a run may spend executing, not counting waits for input")
+	programs := fs.Int("programs", 1000, "most programs the server holds")
+	runs := fs.Int("runs", 1000, "most runs the server holds")
+	concurrent := fs.Int("concurrent", runtime.NumCPU(), "most runs executing at once")
+	ttl := fs.Duration("ttl", time.Hour, "how long an unused program or stopped run is kept")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		log.Fatal("serve takes no arguments")
+	}
+
+	s := newServer(serverLimits{
+		steps:      *steps,
+		memory:     *memory,
+		timeout:    *timeout,
+		programs:   *programs,
+		runs:       *runs,
+		concurrent: *concurrent,
+		ttl:        *ttl,
+	})
+	log.WithField("Addr", *addr).Info("Serving")
+	log.Fatal(http.ListenAndServe(*addr, s.handler()))
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Programs for the server to run: double reads a number and outputs twice it,
+// and loop never stops.
+const (
+	double = "3,9,1002,9,2,9,4,9,99,0"
+	loop   = "1105,1,0"
+)
+
+var testLimits = serverLimits{
+	steps:      1000000,
+	memory:     1 << 16,
+	timeout:    time.Second,
+	programs:   10,
+	runs:       10,
+	concurrent: 4,
+	ttl:        time.Hour,
+}
+
+// call sends body to path on ts, decodes the response into v if it is not nil
+// and returns the status code.
+func call(t *testing.T, ts *httptest.Server, method, path, body string, v interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func upload(t *testing.T, ts *httptest.Server, program string) storedProgram {
+	t.Helper()
+	var p storedProgram
+	if code := call(t, ts, "POST", "/programs", program, &p); code != http.StatusCreated {
+		t.Fatalf("upload = %d, want %d", code, http.StatusCreated)
+	}
+	return p
+}
+
+func startRun(t *testing.T, ts *httptest.Server, program string, req runRequest) runStatus {
+	t.Helper()
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status runStatus
+	if code := call(t, ts, "POST", "/programs/"+program+"/runs", string(body), &status); code != http.StatusCreated {
+		t.Fatalf("start run = %d, want %d", code, http.StatusCreated)
+	}
+	return status
+}
+
+// waitFor polls the run until it is no longer running.
+func waitFor(t *testing.T, ts *httptest.Server, run string) runStatus {
+	t.Helper()
+	for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(time.Millisecond) {
+		var status runStatus
+		if code := call(t, ts, "GET", "/runs/"+run, "", &status); code != http.StatusOK {
+			t.Fatalf("status of run %s = %d", run, code)
+		}
+		if status.State != "running" {
+			return status
+		}
+	}
+	t.Fatalf("run %s never stopped", run)
+	return runStatus{}
+}
+
+func TestServeRun(t *testing.T) {
+	ts := httptest.NewServer(newServer(testLimits).handler())
+	defer ts.Close()
+
+	p := upload(t, ts, double)
+	if p.ID != "1" || p.Size != 10 {
+		t.Errorf("uploaded = %+v", p)
+	}
+	var list []storedProgram
+	call(t, ts, "GET", "/programs", "", &list)
+	if len(list) != 1 || list[0].ID != "1" || list[0].Program != nil {
+		t.Errorf("programs = %+v", list)
+	}
+	if code := call(t, ts, "GET", "/programs/2", "", nil); code != http.StatusNotFound {
+		t.Errorf("missing program = %d, want %d", code, http.StatusNotFound)
+	}
+
+	run := startRun(t, ts, p.ID, runRequest{})
+	if status := waitFor(t, ts, run.ID); status.State != "waiting for input" || status.Done {
+		t.Fatalf("status = %+v, want it waiting for input", status)
+	}
+
+	var status runStatus
+	if code := call(t, ts, "POST", "/runs/"+run.ID+"/input", `{"inputs":[21]}`, &status); code != http.StatusAccepted {
+		t.Fatalf("input = %d, want %d", code, http.StatusAccepted)
+	}
+	status = waitFor(t, ts, run.ID)
+	if status.State != "halted" || !status.Done || fmt.Sprint(status.Output) != "[42]" {
+		t.Errorf("status = %+v, want halted with output 42", status)
+	}
+	if code := call(t, ts, "POST", "/runs/"+run.ID+"/input", `{"inputs":[1]}`, nil); code != http.StatusConflict {
+		t.Errorf("input after halting = %d, want %d", code, http.StatusConflict)
+	}
+}
+
+func TestServeTimeout(t *testing.T) {
+	limits := testLimits
+	limits.timeout = 50 * time.Millisecond
+	ts := httptest.NewServer(newServer(limits).handler())
+	defer ts.Close()
+
+	// Waiting for input longer than the timeout does not count against it.
+	run := startRun(t, ts, upload(t, ts, double).ID, runRequest{})
+	waitFor(t, ts, run.ID)
+	time.Sleep(2 * limits.timeout)
+	call(t, ts, "POST", "/runs/"+run.ID+"/input", `{"inputs":[1]}`, nil)
+	if status := waitFor(t, ts, run.ID); status.State != "halted" {
+		t.Errorf("state = %q, want halted", status.State)
+	}
+
+	run = startRun(t, ts, upload(t, ts, loop).ID, runRequest{})
+	if status := waitFor(t, ts, run.ID); status.State != stateTimedOut {
+		t.Errorf("state = %q, want %q", status.State, stateTimedOut)
+	}
+}
+
+func TestServeLimits(t *testing.T) {
+	limits := testLimits
+	limits.programs, limits.runs, limits.concurrent = 2, 3, 1
+	s := newServer(limits)
+	var mu sync.Mutex
+	now := time.Now()
+	s.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	ts := httptest.NewServer(s.handler())
+	defer ts.Close()
+
+	doubler, looper := upload(t, ts, double).ID, upload(t, ts, loop).ID
+	if code := call(t, ts, "POST", "/programs", double, nil); code != http.StatusServiceUnavailable {
+		t.Errorf("upload past the limit = %d, want %d", code, http.StatusServiceUnavailable)
+	}
+
+	// Only one run may execute at once, but one waiting for input is not
+	// executing.
+	waiting := startRun(t, ts, doubler, runRequest{})
+	waitFor(t, ts, waiting.ID)
+	looping := startRun(t, ts, looper, runRequest{})
+	if code := call(t, ts, "POST", "/programs/"+doubler+"/runs", "", nil); code != http.StatusServiceUnavailable {
+		t.Errorf("run past the concurrency limit = %d, want %d", code, http.StatusServiceUnavailable)
+	}
+	if code := call(t, ts, "POST", "/runs/"+waiting.ID+"/input", `{"inputs":[1]}`, nil); code != http.StatusServiceUnavailable {
+		t.Errorf("input past the concurrency limit = %d, want %d", code, http.StatusServiceUnavailable)
+	}
+	waitFor(t, ts, looping.ID)
+
+	waitFor(t, ts, startRun(t, ts, doubler, runRequest{Inputs: []int{1}}).ID)
+	if code := call(t, ts, "POST", "/programs/"+doubler+"/runs", "", nil); code != http.StatusServiceUnavailable {
+		t.Errorf("run past the limit = %d, want %d", code, http.StatusServiceUnavailable)
+	}
+
+	// Once the TTL passes unused programs and runs are forgotten, making
+	// room for more.
+	mu.Lock()
+	now = now.Add(limits.ttl + time.Second)
+	mu.Unlock()
+	if code := call(t, ts, "GET", "/runs/"+waiting.ID, "", nil); code != http.StatusNotFound {
+		t.Errorf("evicted run = %d, want %d", code, http.StatusNotFound)
+	}
+	if code := call(t, ts, "GET", "/programs/"+doubler, "", nil); code != http.StatusNotFound {
+		t.Errorf("evicted program = %d, want %d", code, http.StatusNotFound)
+	}
+	p := upload(t, ts, double)
+	if p.ID != "3" {
+		t.Errorf("ID after eviction = %s, want 3", p.ID)
+	}
+	startRun(t, ts, p.ID, runRequest{Inputs: []int{1}})
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/dannyxd11/AoC2019/intcode"
+	log "github.com/sirupsen/logrus"
+)
+
+// clearScreen moves the cursor home and clears the terminal.
+const clearScreen = "\x1b[H\x1b[2J"
+
+// watch runs a program one frame at a time, redrawing its memory after each.
+func watch(args []string) {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	inputs := fs.String("inputs", "", "comma separated inputs for the program")
+	width := fs.Int("width", 10, "cells in each row")
+	steps := fs.Int("steps", 1, "instructions to execute between frames")
+	delay := fs.Duration("delay", 100*time.Millisecond, "pause between frames")
+	htmlPath := fs.String("html", "", "also write each frame to this file as an HTML page")
+	engine := fs.String("engine", intcode.Interpreted.String(), "execution engine: interpreted or compiled")
+	lenient := fs.Bool("lenient", false, "treat immediate mode write parameters as positions instead of failing")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("watch needs exactly one program file")
+	}
+	if *width < 1 || *steps < 1 {
+		log.Fatal("width and steps must be at least 1")
+	}
+
+	m := intcode.NewFromImage(loadProgram(fs.Arg(0)))
+	m.SetEngine(engineFor(*engine))
+	if *lenient {
+		m.WriteMode = intcode.LenientWrites
+	}
+	output := &intcode.Queue{}
+	m.Input, m.Output = intcode.NewQueue(parseInputs(*inputs)...), output
+
+	v := intcode.NewVisualizer()
+	v.Width = *width
+	m.Observe(v)
+
+	// Refresh the page about as often as frames are drawn.
+	refresh := int(delay.Seconds() + 0.5)
+	if refresh < 1 {
+		refresh = 1
+	}
+	draw := func(final bool) {
+		fmt.Print(clearScreen)
+		if err := v.Render(os.Stdout, m); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("output:", output.Values())
+		if *htmlPath == "" {
+			return
+		}
+		if final {
+			refresh = 0
+		}
+		err := writeFile(*htmlPath, func(w io.Writer) error {
+			return v.RenderHTML(w, m, refresh)
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	draw(false)
+	for {
+		var err error
+		for i := 0; i < *steps && err == nil && m.Status() == intcode.Running; i++ {
+			err = m.Step()
+		}
+		if err != nil || m.Status() != intcode.Running {
+			draw(true)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if m.Status() == intcode.WaitingInput {
+				log.Fatal("Program is waiting for more input than was given")
+			}
+			return
+		}
+		draw(false)
+		time.Sleep(*delay)
+	}
+}
@@ -1,13 +1,12 @@
 package main
 
 import (
-	"bufio"
+	"flag"
 	"fmt"
+	"github.com/dannyxd11/AoC2019/intcode"
 	"io"
 	"log"
 	"os"
-	"strconv"
-	"strings"
 )
 
 func check(e error) {
@@ -23,42 +22,20 @@ type Operation struct {
 	opc    int
 }
 
-type pair [2]int
-
-func cartesian(a, b []int) []pair {
-	p := make([]pair, len(a)*len(b))
-	i := 0
-	for _, a := range a {
-		for _, b := range b {
-			p[i] = pair{a, b}
-			i++
-		}
-	}
-	return p
-}
+func parseOp(ops []int) (op Operation, isTerminated bool) {
+	opcode := ops[0]
 
-func makeRange(min, max int) []int {
-	a := make([]int, max-min+1)
-	for i := range a {
-		a[i] = min + i
+	// A halt has no parameters and may be the last value in the program
+	if opcode == 99 {
+		return Operation{opcode: opcode}, true
 	}
-	return a
-}
 
-func parseOp(ops []int) (op Operation, isTerminated bool) {
-	opcode := ops[0]
 	opa := ops[1]
 	opb := ops[2]
 	opc := ops[3]
 
-	if opcode == 99 {
-		isTerminated = true
-	} else {
-		isTerminated = false
-	}
-
 	op = Operation{opcode, opa, opb, opc}
-	return
+	return op, false
 }
 
 func execInstruction(program *[]int, op Operation) {
@@ -76,7 +53,7 @@ func execInstruction(program *[]int, op Operation) {
 func execute(program *[]int) (output []int) {
 
 	for i := 0; i < len(*program); i += 4 {
-		op, isTerminated := parseOp((*program)[i : i+4])
+		op, isTerminated := parseOp((*program)[i:])
 
 		if isTerminated {
 			break
@@ -89,22 +66,7 @@ func execute(program *[]int) (output []int) {
 }
 
 func part1(file io.ReadSeeker) {
-	_, err := file.Seek(0, io.SeekStart)
-	check(err)
-	scanner := bufio.NewScanner(file)
-
-	// Move to first line
-	scanner.Scan()
-
-	program_s := strings.Split(scanner.Text(), ",")
-
-	// Cast elements to int
-	var program = []int{}
-	for _, i := range program_s {
-		j, err := strconv.Atoi(i)
-		check(err)
-		program = append(program, j)
-	}
+	program := load(file)
 
 	//fmt.Println("[Part 1] Original Program:",program);
 	program[1] = 12
@@ -115,50 +77,35 @@ func part1(file io.ReadSeeker) {
 	fmt.Println("[Part 1] Result:", program[0])
 }
 
-func part2(file io.ReadSeeker) {
+func load(file io.ReadSeeker) []int {
 	_, err := file.Seek(0, io.SeekStart)
 	check(err)
-	scanner := bufio.NewScanner(file)
 
-	// Move to first line
-	scanner.Scan()
+	program, err := intcode.ReadProgram(file)
+	check(err)
+	return program
+}
 
-	program_s := strings.Split(scanner.Text(), ",")
+func part2(file io.ReadSeeker, target int, inputs inputRange) {
+	program := load(file)
 
-	// Cast elements to int
-	var program = []int{}
-	for _, i := range program_s {
-		j, err := strconv.Atoi(i)
-		if err != nil {
-			panic(err)
-		}
-		program = append(program, j)
+	if e, err := symbolicResult(program); err == nil {
+		fmt.Println("[Part 2] Position 0 =", e)
 	}
 
-	inputs := makeRange(0, 99)
-	input_pairs := cartesian(inputs, inputs)
-
-	for _, v := range input_pairs {
-		var i_program = make([]int, len(program))
-		copy(i_program, program)
-
-		noun, verb := v[0], v[1]
-		i_program[1] = noun
-		i_program[2] = verb
-
-		execute(&i_program)
-
-		result := i_program[0]
-
-		if result == 19690720 {
-			fmt.Printf("[Part 2] 100 * noun [%d] + verb [%d] = %d", noun, verb, 100*noun+verb)
-			break
-		}
+	noun, verb, err := solve(program, target, inputs, inputs)
+	if err != nil {
+		fmt.Printf("[Part 2] %v: %d\n", err, target)
+		return
 	}
-
+	fmt.Printf("[Part 2] 100 * noun [%d] + verb [%d] = %d\n", noun, verb, 100*noun+verb)
 }
 
 func main() {
+	target := flag.Int("target", 19690720, "value to produce at position 0 in part 2")
+	min := flag.Int("min", 0, "smallest noun and verb to consider")
+	max := flag.Int("max", 99, "largest noun and verb to consider")
+	flag.Parse()
 
 	file, err := os.Open("./challenge.txt")
 	if err != nil {
@@ -170,6 +117,6 @@ func main() {
 	}()
 
 	part1(file)
-	part2(file)
+	part2(file, *target, inputRange{*min, *max})
 
 }
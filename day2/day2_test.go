@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/dannyxd11/AoC2019/intcode"
+)
+
+var interpreter = intcode.Interpreter{
+	Name: "day2",
+	Run: func(program []int, inputs []int) (res intcode.Result) {
+		defer func() {
+			if r := recover(); r != nil {
+				res.Err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		execute(&program)
+		return intcode.Result{Memory: program}
+	},
+}
+
+func TestDifferential(t *testing.T) {
+	cases, err := intcode.Corpus("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range intcode.Differential(cases, interpreter) {
+		t.Error(d)
+	}
+}
+
+func TestSolve(t *testing.T) {
+	tests := []struct {
+		name    string
+		program []int
+		target  int
+		affine  string
+		noun    int
+		verb    int
+	}{
+		{"sum", []int{1, 0, 0, 3, 1, 1, 2, 0, 99}, 10, "0 + 1*noun + 1*verb", 0, 10},
+		{"scaled", []int{1, 0, 0, 3, 1002, 1, 99, 3, 1, 3, 2, 0, 99}, 4242, "0 + 99*noun + 1*verb", 42, 84},
+		// Position 0 is read through the noun and verb as addresses, so
+		// only a search finds it.
+		{"indirect", []int{1, 0, 0, 0, 99, 5, 6, 7}, 13, "", 2, 13},
+	}
+	for _, tt := range tests {
+		e, err := symbolicResult(tt.program)
+		if got := fmt.Sprint(e); (err == nil) != (tt.affine != "") || err == nil && got != tt.affine {
+			t.Errorf("%s: position 0 = %s, %v, want %q", tt.name, got, err, tt.affine)
+		}
+		noun, verb, err := solve(tt.program, tt.target, inputRange{0, 99}, inputRange{0, 99})
+		if err != nil || noun != tt.noun || verb != tt.verb {
+			t.Errorf("%s: solve = %d, %d, %v, want %d, %d", tt.name, noun, verb, err, tt.noun, tt.verb)
+		}
+	}
+}
+
+// TestSearch checks the brute force search agrees with the solver.
+func TestSearch(t *testing.T) {
+	file, err := os.Open("challenge.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	program := load(file)
+
+	noun, verb, err := solve(program, 19690720, inputRange{0, 99}, inputRange{0, 99})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotNoun, gotVerb, err := search(program, 19690720, inputRange{0, 99}, inputRange{0, 99})
+	if err != nil || gotNoun != noun || gotVerb != verb {
+		t.Errorf("search = %d, %d, %v, want %d, %d", gotNoun, gotVerb, err, noun, verb)
+	}
+}
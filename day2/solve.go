@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/dannyxd11/AoC2019/intcode"
+)
+
+// affine is the value c + n*noun + v*verb.
+type affine struct {
+	c, n, v int
+}
+
+func (e affine) String() string {
+	return fmt.Sprintf("%d + %d*noun + %d*verb", e.c, e.n, e.v)
+}
+
+var errNotSymbolic = errors.New("program cannot be executed symbolically")
+
+// symbolicResult runs program once with noun and verb left as unknowns and
+// returns position 0 in terms of them. It fails if the control flow or a write
+// address would depend on the inputs, or position 0 is not affine in them.
+func symbolicResult(program []int) (affine, error) {
+	opts := intcode.DefaultSymbolicOptions
+	opts.Unknowns = []int{1, 2}
+	opts.OpaqueReads = true
+	// A second path means the program branched on the inputs.
+	opts.MaxPaths = 2
+
+	paths := intcode.Explore(program, opts)
+	if len(paths) != 1 {
+		return affine{}, fmt.Errorf("%w: control flow depends on the noun or verb", errNotSymbolic)
+	}
+	p := paths[0]
+	if p.End != intcode.PathHalted {
+		return affine{}, fmt.Errorf("%w: %v %v", errNotSymbolic, p.End, p.Err)
+	}
+	coeffs, c, ok := p.Cell(0).Linear()
+	if !ok {
+		return affine{}, fmt.Errorf("%w: position 0 is %v", errNotSymbolic, p.Cell(0))
+	}
+	return affine{c, coeffs[0], coeffs[1]}, nil
+}
+
+type inputRange struct {
+	min, max int
+}
+
+func (r inputRange) contains(x int) bool {
+	return x >= r.min && x <= r.max
+}
+
+var errNoSolution = errors.New("no noun and verb produce the target")
+
+// solve finds the noun and verb within the given ranges for which program
+// leaves target at position 0, preferring the smallest noun. When position 0
+// is an affine function of the inputs it is solved directly and the answer
+// checked by running the program. Otherwise, or if the check fails, every
+// pair is tried.
+func solve(program []int, target int, nouns, verbs inputRange) (noun, verb int, err error) {
+	if e, err := symbolicResult(program); err == nil {
+		if noun, verb, ok := solveAffine(e, target, nouns, verbs); ok {
+			if result, ok := run(intcode.New(program), noun, verb); ok && result == target {
+				return noun, verb, nil
+			}
+			log.Printf("Solved %d, %d from %v but the program disagrees, searching instead", noun, verb, e)
+		}
+	}
+	return search(program, target, nouns, verbs)
+}
+
+// solveAffine solves c + a*noun + b*verb = target as a linear Diophantine
+// equation, restricted to the input ranges.
+func solveAffine(e affine, target int, nouns, verbs inputRange) (int, int, bool) {
+	a, b, r := e.n, e.v, target-e.c
+	switch {
+	case a == 0 && b == 0:
+		return nouns.min, verbs.min, r == 0 && nouns.min <= nouns.max && verbs.min <= verbs.max
+	case b == 0:
+		if r%a != 0 || !nouns.contains(r/a) || verbs.min > verbs.max {
+			return 0, 0, false
+		}
+		return r / a, verbs.min, true
+	case a == 0:
+		if r%b != 0 || !verbs.contains(r/b) || nouns.min > nouns.max {
+			return 0, 0, false
+		}
+		return nouns.min, r / b, true
+	}
+
+	g, x, y := extendedGcd(a, b)
+	if r%g != 0 {
+		return 0, 0, false
+	}
+	// Every solution is noun = n0 + k*sn, verb = v0 - k*sv for integer k.
+	n0, v0 := x*(r/g), y*(r/g)
+	sn, sv := b/g, a/g
+
+	lo, hi, ok := stepRange(n0, sn, nouns)
+	if !ok {
+		return 0, 0, false
+	}
+	vlo, vhi, ok := stepRange(v0, -sv, verbs)
+	if !ok {
+		return 0, 0, false
+	}
+	if vlo > lo {
+		lo = vlo
+	}
+	if vhi < hi {
+		hi = vhi
+	}
+	if lo > hi {
+		return 0, 0, false
+	}
+
+	k := lo
+	if sn < 0 {
+		k = hi
+	}
+	return n0 + k*sn, v0 - k*sv, true
+}
+
+// stepRange returns the range of k for which x0 + k*step lies within r.
+func stepRange(x0, step int, r inputRange) (int, int, bool) {
+	lo, hi := intcode.CeilDiv(r.min-x0, step), intcode.FloorDiv(r.max-x0, step)
+	if step < 0 {
+		lo, hi = intcode.CeilDiv(r.max-x0, step), intcode.FloorDiv(r.min-x0, step)
+	}
+	return lo, hi, lo <= hi
+}
+
+func extendedGcd(a, b int) (g, x, y int) {
+	if b == 0 {
+		if a < 0 {
+			return -a, -1, 0
+		}
+		return a, 1, 0
+	}
+	g, x1, y1 := extendedGcd(b, a%b)
+	return g, y1, x1 - (a/b)*y1
+}
+
+// search runs the program for every noun and verb pair in range. Pairs that
+// crash the program, e.g. by addressing past its end, are skipped.
+func search(program []int, target int, nouns, verbs inputRange) (int, int, error) {
+	// Each run starts from a pooled machine that shares the program's memory
+	// rather than copying all of it.
+	pool := intcode.NewPool(intcode.New(program))
+	for noun := nouns.min; noun <= nouns.max; noun++ {
+		for verb := verbs.min; verb <= verbs.max; verb++ {
+			m := pool.Get()
+			result, ok := run(m, noun, verb)
+			pool.Put(m)
+			if ok && result == target {
+				return noun, verb, nil
+			}
+		}
+	}
+	return 0, 0, errNoSolution
+}
+
+// run patches noun and verb into m and runs it, returning position 0.
+func run(m *intcode.Machine, noun, verb int) (result int, ok bool) {
+	if m.Poke(1, noun) != nil || m.Poke(2, verb) != nil {
+		return 0, false
+	}
+	if err := m.Run(); err != nil || m.Status() != intcode.Halted {
+		return 0, false
+	}
+	result, err := m.Peek(0)
+	return result, err == nil
+}
@@ -2,12 +2,14 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"flag"
 	"fmt"
+	"github.com/dannyxd11/AoC2019/intcode"
 	log "github.com/sirupsen/logrus"
 	"io"
 	"os"
 	"strconv"
-	"strings"
 )
 
 func check(e error) {
@@ -33,6 +35,13 @@ type Parameter struct {
 	mode int
 }
 
+type Output struct {
+	val       int
+	hasOutput bool
+	// ip is the address of the instruction that produced the output.
+	ip int
+}
+
 type Operation struct {
 	opcode  int
 	params  []Parameter
@@ -71,6 +80,46 @@ func parseOp(program []int) (op Operation, isTerminated bool) {
 	return
 }
 
+// extraInputs supplies the inputs a program asks for beyond those it was
+// given, from stdin with -interactive or from a recording with -replay. If it
+// is nil running out of inputs is an error, so tests and piped runs cannot
+// block waiting for one.
+var extraInputs intcode.Input
+
+// prompt reads inputs typed at stdin.
+type prompt struct{}
+
+func (prompt) Read() (int, error) {
+	return getInput(), nil
+}
+
+// recording keeps the inputs read from in, so that an interactive run can be
+// replayed.
+type recording struct {
+	in     intcode.Input
+	values []int
+}
+
+func (r *recording) Read() (int, error) {
+	val, err := r.in.Read()
+	if err == nil {
+		r.values = append(r.values, val)
+	}
+	return val, err
+}
+
+func getInput() int {
+	buf := bufio.NewReader(os.Stdin)
+	fmt.Print("For Part 1 provide 1, for Part 2 provide 5. 1 digit only.\n> ")
+
+	input, err := buf.ReadByte()
+	check(err)
+	iInput, err := strconv.Atoi(string(input))
+	check(err)
+
+	return iInput
+}
+
 func getVal(program *[]int, param Parameter) int {
 	if param.mode == 0 {
 		return (*program)[param.val]
@@ -90,8 +139,9 @@ func setVal(program *[]int, index int, val int) int {
 	return 0
 }
 
-func execInstruction(program *[]int, op Operation, ip int) int {
+func execInstruction(program *[]int, op Operation, ip int, input int) (i int, output Output) {
 	log.WithFields(log.Fields{"op": op, "ip": ip}).Debug("Executing Operation")
+	output = Output{0, false, ip}
 	ip += op.nParams + 1
 	if op.opcode == 1 {
 		setVal(program,
@@ -104,22 +154,15 @@ func execInstruction(program *[]int, op Operation, ip int) int {
 			getVal(program, op.params[0])*getVal(program, op.params[1]),
 		)
 	} else if op.opcode == 3 {
-		buf := bufio.NewReader(os.Stdin)
-		fmt.Print("For Part 1 provide 1, for Part 2 provide 5. 1 digit only.\n> ")
-
-		input, err := buf.ReadByte()
-		check(err)
-		iInput, err := strconv.Atoi(string(input))
-		check(err)
-
 		setVal(program,
 			op.params[0].val,
-			iInput,
+			input,
 		)
 	} else if op.opcode == 4 {
+		output.val, output.hasOutput = getVal(program, op.params[0]), true
 		log.WithFields(log.Fields{
 			"out": getVal(program, op.params[0]),
-		}).Info("Operation 4 Output")
+		}).Debug("Operation 4 Output")
 	} else if op.opcode == 5 {
 		if getVal(program, op.params[0]) != 0 {
 			ip = getVal(program, op.params[1])
@@ -148,20 +191,41 @@ func execInstruction(program *[]int, op Operation, ip int) int {
 		panic(fmt.Sprintf("Unrecognised opcode: %d", op.opcode))
 	}
 	log.WithFields(log.Fields{"op": op, "ip": ip}).Debug("Executed Operation")
-	return ip
+	return ip, output
+}
+
+// window returns up to four cells from i for logging, without running off the
+// end of a program whose final instruction is shorter than that.
+func window(program []int, i int) []int {
+	end := i + 4
+	if end > len(program) {
+		end = len(program)
+	}
+	return program[i:end]
+}
+
+// execute runs program, reading from inputs before falling back to prompting
+// on extraInputs, and returns everything it output.
+func execute(program *[]int, inputs []int) (output []int) {
+	for _, out := range run(program, inputs) {
+		output = append(output, out.val)
+	}
+	return
 }
 
-func execute(program *[]int) (output []int) {
+// run is execute, keeping where each output came from.
+func run(program *[]int, inputs []int) (outputs []Output) {
+	nInput := 0
 
 	for i := 0; i < len(*program); { //i++{
 		log.WithFields(log.Fields{
 			"i":              i,
-			"program[i:i+4]": (*program)[i : i+4],
+			"program[i:i+4]": window(*program, i),
 		}).Trace("Parsing op")
 		op, isTerminated := parseOp((*program)[i:])
 		log.WithFields(log.Fields{
 			"i":              i,
-			"program[i:i+4]": (*program)[i : i+4],
+			"program[i:i+4]": window(*program, i),
 			"op":             op,
 			"isTerminated":   isTerminated,
 		}).Trace("Parsed op")
@@ -169,39 +233,111 @@ func execute(program *[]int) (output []int) {
 			break
 		}
 
-		i = execInstruction(program, op, i)
+		var input = 0
+		if op.opcode == 3 {
+			if nInput < len(inputs) {
+				input = inputs[nInput]
+				nInput++
+			} else if extraInputs != nil {
+				val, err := extraInputs.Read()
+				check(err)
+				input = val
+			} else {
+				panic(fmt.Sprintf("Instruction at %d needs input %d but only %d were given", i, nInput+1, len(inputs)))
+			}
+		}
+
+		ip, out := execInstruction(program, op, i, input)
+		i = ip
+		if out.hasOutput {
+			outputs = append(outputs, out)
+		}
 	}
 
 	return
 }
 
+// TestFailure is a diagnostic test that output something other than 0.
+type TestFailure struct {
+	Index int
+	Ip    int
+	Val   int
+}
+
+func (f TestFailure) Error() string {
+	return fmt.Sprintf("test %d (output by the instruction at %d) failed with %d", f.Index, f.Ip, f.Val)
+}
+
+// diagnose runs the diagnostic program for systemID. Every output but the
+// last is a test result, which must be 0, and the last is the diagnostic code.
+func diagnose(program []int, systemID int) (code int, err error) {
+	memory := append([]int(nil), program...)
+	outputs := run(&memory, []int{systemID})
+	if len(outputs) == 0 {
+		return 0, fmt.Errorf("system %d: no diagnostic code was output", systemID)
+	}
+	for i, out := range outputs[:len(outputs)-1] {
+		if out.val != 0 {
+			return 0, TestFailure{i, out.ip, out.val}
+		}
+	}
+	log.WithFields(log.Fields{"System": systemID, "Tests": len(outputs) - 1}).Debug("Diagnostic tests passed")
+	return outputs[len(outputs)-1].val, nil
+}
+
 func loadAndRun(file io.ReadSeeker) {
 	_, err := file.Seek(0, io.SeekStart)
 	check(err)
 
-	scanner := bufio.NewScanner(file)
-
-	// Move to first line
-	scanner.Scan()
-
-	sProgram := strings.Split(scanner.Text(), ",")
+	program, err := intcode.ReadProgram(file)
+	check(err)
 
-	// Cast elements to int
-	var program []int
-	for _, i := range sProgram {
-		j, err := strconv.Atoi(i)
+	// System 1 is the air conditioner (part 1), system 5 the thermal
+	// radiator controller (part 2).
+	for _, systemID := range []int{1, 5} {
+		code, err := diagnose(program, systemID)
 		if err != nil {
-			panic(err)
+			log.WithField("System", systemID).Error(err)
+			continue
 		}
-		program = append(program, j)
+		fmt.Printf("System %d diagnostic code: %d\n", systemID, code)
 	}
-
-	execute(&program)
 }
 
 func main() {
+	interactive := flag.Bool("interactive", false, "prompt on stdin for inputs beyond the system IDs")
+	record := flag.String("record", "", "file to record the inputs typed with -interactive to")
+	replay := flag.String("replay", "", "file of inputs recorded with -record to use instead of prompting")
+	flag.Parse()
 	log.SetLevel(log.InfoLevel)
 
+	switch {
+	case *replay != "" && *interactive:
+		log.Fatal("-replay and -interactive cannot be used together")
+	case *record != "" && !*interactive:
+		log.Fatal("-record needs -interactive")
+	case *replay != "":
+		recorded, err := os.ReadFile(*replay)
+		if err != nil {
+			log.Fatal(err)
+		}
+		values, err := intcode.ReadProgram(bytes.NewReader(recorded))
+		if err != nil {
+			log.Fatalf("%s: %v", *replay, err)
+		}
+		extraInputs = intcode.NewQueue(values...)
+	case *record != "":
+		r := &recording{in: prompt{}}
+		extraInputs = r
+		defer func() {
+			var b bytes.Buffer
+			check(intcode.WriteProgram(&b, r.values))
+			check(os.WriteFile(*record, b.Bytes(), 0644))
+		}()
+	case *interactive:
+		extraInputs = prompt{}
+	}
+
 	file, err := os.Open("./challenge.txt")
 	if err != nil {
 		log.Fatal(err)
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/dannyxd11/AoC2019/intcode"
+)
+
+var interpreter = intcode.Interpreter{
+	Name:     "day5",
+	Supports: intcode.FeatureIO | intcode.FeatureJumps | intcode.FeatureComparisons | intcode.FeatureImmediate,
+	Run: func(program []int, inputs []int) (res intcode.Result) {
+		defer func() {
+			if r := recover(); r != nil {
+				res.Err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		output := execute(&program, inputs)
+		return intcode.Result{Outputs: output, Memory: program}
+	},
+}
+
+func TestDifferential(t *testing.T) {
+	cases, err := intcode.Corpus("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range intcode.Differential(cases, interpreter) {
+		t.Error(d)
+	}
+}
+
+func TestRunOutOfInputs(t *testing.T) {
+	// Reads twice but is given one input, which must fail rather than wait
+	// on stdin.
+	res := interpreter.Run([]int{3, 0, 3, 0, 4, 0, 99}, []int{1})
+	if res.Err == nil || !strings.Contains(res.Err.Error(), "needs input 2") {
+		t.Errorf("err = %v, want one for the missing input", res.Err)
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	defer func() { extraInputs = nil }()
+	echoTwice := []int{3, 0, 4, 0, 3, 0, 4, 0, 99}
+
+	// Record the second input, as if typed at the prompt.
+	r := &recording{in: intcode.NewQueue(9)}
+	extraInputs = r
+	recorded := interpreter.Run(append([]int(nil), echoTwice...), []int{4})
+	if recorded.Err != nil || fmt.Sprint(r.values) != "[9]" {
+		t.Fatalf("err = %v, recorded %v, want [9]", recorded.Err, r.values)
+	}
+
+	var b bytes.Buffer
+	if err := intcode.WriteProgram(&b, r.values); err != nil {
+		t.Fatal(err)
+	}
+	values, err := intcode.ReadProgram(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extraInputs = intcode.NewQueue(values...)
+	replayed := interpreter.Run(append([]int(nil), echoTwice...), []int{4})
+	if replayed.Err != nil || fmt.Sprint(replayed.Outputs) != fmt.Sprint(recorded.Outputs) {
+		t.Errorf("replayed %v (%v), want %v", replayed.Outputs, replayed.Err, recorded.Outputs)
+	}
+}
+
+func TestDiagnose(t *testing.T) {
+	file, err := os.Open("challenge.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	program, err := intcode.ReadProgram(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, systemID := range []int{1, 5} {
+		if _, err := diagnose(program, systemID); err != nil {
+			t.Errorf("system %d: %v", systemID, err)
+		}
+	}
+
+	// Pass one test, fail the next and then output a code.
+	failing := []int{104, 0, 104, 3, 104, 42, 99}
+	_, err = diagnose(failing, 1)
+	if want := (TestFailure{1, 2, 3}); err != want {
+		t.Errorf("got %v, want %v", err, want)
+	}
+	if _, err := diagnose([]int{99}, 1); err == nil {
+		t.Error("a program without output passed")
+	}
+}
+
+func TestInputsForDiagnosticCode(t *testing.T) {
+	file, err := os.Open("challenge.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	program, err := intcode.ReadProgram(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := diagnose(program, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Working backwards from the code has to find the system ID it came from.
+	opts := intcode.DefaultSymbolicOptions
+	opts.MinInput, opts.MaxInput = 0, 10
+	inputs, err := intcode.InputsForOutput(program, code, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) != 1 || inputs[0] != 5 {
+		t.Errorf("inputs = %v, want [5]", inputs)
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/dannyxd11/AoC2019/intcode"
+)
+
+// interpreter runs a case on an amplifier set up as the circuits set them up.
+// The amplifiers are Machines, so unlike the other days this is not an
+// independent implementation; it only catches the set-up, such as lenient
+// writes, changing a result.
+var interpreter = intcode.Interpreter{
+	Name:     "day7",
+	Supports: intcode.FeatureIO | intcode.FeatureJumps | intcode.FeatureComparisons | intcode.FeatureImmediate,
+	Run: func(program []int, inputs []int) (res intcode.Result) {
+		m := amplifierPool(program).Get()
+		out := &intcode.Queue{}
+		m.Input, m.Output = intcode.NewQueue(inputs...), out
+		res.Err = m.Run()
+		if res.Err == nil && m.Status() == intcode.WaitingInput {
+			res.Err = intcode.ErrNeedsInput
+		}
+		res.Outputs, res.Memory = out.Values(), m.Memory()
+		return res
+	},
+}
+
+func TestDifferential(t *testing.T) {
+	cases, err := intcode.Corpus("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range intcode.Differential(cases, interpreter) {
+		t.Error(d)
+	}
+}
+
+var (
+	seriesExample1 = []int{3, 15, 3, 16, 1002, 16, 10, 16, 1, 16, 15, 15, 4, 15, 99, 0, 0}
+	seriesExample2 = []int{
+		3, 23, 3, 24, 1002, 24, 10, 24, 1002, 23, -1, 23,
+		101, 5, 23, 23, 1, 24, 23, 23, 4, 23, 99, 0, 0,
+	}
+	seriesExample3 = []int{
+		3, 31, 3, 32, 1002, 32, 10, 32, 1001, 31, -2, 31, 1007, 31, 0, 33,
+		1002, 33, 7, 33, 1, 33, 31, 31, 1, 32, 31, 31, 4, 31, 99, 0, 0, 0,
+	}
+	feedbackExample1 = []int{
+		3, 26, 1001, 26, -4, 26, 3, 27, 1002, 27, 2, 27, 1, 27, 26,
+		27, 4, 27, 1001, 28, -1, 28, 1005, 28, 6, 99, 0, 0, 5,
+	}
+	feedbackExample2 = []int{
+		3, 52, 1001, 52, -5, 52, 3, 53, 1, 52, 56, 54, 1007, 54, 5, 55, 1005, 55, 26, 1001, 54,
+		-5, 54, 1105, 1, 12, 1, 53, 54, 53, 1008, 54, 0, 55, 1001, 55, 1, 55, 2, 53, 55, 53, 4,
+		53, 1001, 56, -1, 56, 1005, 56, 6, 99, 0, 0, 0, 0, 10,
+	}
+)
+
+func TestMaxSignal(t *testing.T) {
+	series, feedback := []int{0, 1, 2, 3, 4}, []int{5, 6, 7, 8, 9}
+	tests := []struct {
+		name       string
+		circuit    Circuit
+		phases     []int
+		wantSignal int
+		wantPhases []int
+	}{
+		{"series example 1", newSeriesCircuit(seriesExample1), series, 43210, []int{4, 3, 2, 1, 0}},
+		{"series example 2", newSeriesCircuit(seriesExample2), series, 54321, []int{0, 1, 2, 3, 4}},
+		{"series example 3", newSeriesCircuit(seriesExample3), series, 65210, []int{1, 0, 4, 3, 2}},
+		{"feedback example 1", newFeedbackCircuit(feedbackExample1), feedback, 139629729, []int{9, 8, 7, 6, 5}},
+		{"feedback example 2", newFeedbackCircuit(feedbackExample2), feedback, 18216, []int{9, 7, 8, 5, 6}},
+	}
+	strategies := map[string]Strategy{"exhaustive": Exhaustive{}, "bnb": BranchAndBound{}}
+
+	for _, tt := range tests {
+		for name, strategy := range strategies {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				signal, err := tt.circuit.Signal(tt.wantPhases)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if signal != tt.wantSignal {
+					t.Errorf("Signal(%v) = %d, want %d", tt.wantPhases, signal, tt.wantSignal)
+				}
+
+				res, err := strategy.Search(tt.circuit, tt.phases)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if res.Signal != tt.wantSignal || !res.Optimal {
+					t.Errorf("Search() = %d (optimal %t), want %d (optimal)", res.Signal, res.Optimal, tt.wantSignal)
+				}
+			})
+		}
+	}
+}
+
+// weightedCircuit scores a setting by the sum of each phase times its
+// position, so the phases in ascending order always score highest and any
+// other setting can be improved by a swap.
+type weightedCircuit struct{}
+
+func (weightedCircuit) Signal(phases []int) (int, error) {
+	signal := 0
+	for i, p := range phases {
+		signal += i * p
+	}
+	return signal, nil
+}
+
+func TestLocalSearch(t *testing.T) {
+	phases := []int{7, 3, 5, 0, 6, 1, 4, 2}
+	res, err := LocalSearch{Seed: 1}.Search(weightedCircuit{}, phases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(res.Phases) != "[0 1 2 3 4 5 6 7]" || res.Signal != 140 || res.Optimal {
+		t.Errorf("Search() = %v %d (optimal %t), want [0 1 2 3 4 5 6 7] 140 (not optimal)", res.Phases, res.Signal, res.Optimal)
+	}
+
+	// The same seed repeats the same search, and each restart adds to it.
+	circuit := newFeedbackCircuit(feedbackExample2)
+	feedback := []int{5, 6, 7, 8, 9}
+	once, err := LocalSearch{Seed: 3, Restarts: 1}.Search(circuit, feedback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := LocalSearch{Seed: 3, Restarts: 1}.Search(circuit, feedback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(once) != fmt.Sprint(again) {
+		t.Errorf("seed 3 gave %v then %v", once, again)
+	}
+	restarted, err := LocalSearch{Seed: 3, Restarts: 10}.Search(circuit, feedback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restarted.Evaluations <= once.Evaluations || restarted.Signal < once.Signal {
+		t.Errorf("10 restarts gave %v, 1 gave %v", restarted, once)
+	}
+	if restarted.Signal != 18216 {
+		t.Errorf("10 restarts found %d, want 18216", restarted.Signal)
+	}
+
+	if res, _ := (LocalSearch{}).Search(weightedCircuit{}, []int{4}); !res.Optimal {
+		t.Error("a single phase was not reported optimal")
+	}
+}
+
+func TestMaxEvaluations(t *testing.T) {
+	series := []int{0, 1, 2, 3, 4}
+	for name, strategy := range map[string]Strategy{
+		"exhaustive": Exhaustive{MaxEvaluations: 10},
+		"bnb":        BranchAndBound{MaxEvaluations: 7},
+	} {
+		res, err := strategy.Search(newSeriesCircuit(seriesExample1), series)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Optimal || res.Evaluations > 10 || res.Phases == nil {
+			t.Errorf("%s: %+v, want a capped, non-optimal result", name, res)
+		}
+	}
+
+	// Permutations are made as they are needed, so a cap makes even a
+	// search over 20! settings quick.
+	many := make([]int, 20)
+	for i := range many {
+		many[i] = i
+	}
+	res, err := Exhaustive{MaxEvaluations: 100}.Search(weightedCircuit{}, many)
+	if err != nil || res.Evaluations != 100 || res.Optimal {
+		t.Errorf("Search() = %+v, %v, want 100 evaluations", res, err)
+	}
+
+	res, err = Exhaustive{MaxEvaluations: 120}.Search(newSeriesCircuit(seriesExample1), series)
+	if err != nil || !res.Optimal || res.Signal != 43210 {
+		t.Errorf("a cap of exactly 5! gave %+v, %v, want the optimum", res, err)
+	}
+}
+
+func TestBranchAndBoundTooManyPhases(t *testing.T) {
+	phases := make([]int, 65)
+	for i := range phases {
+		phases[i] = i
+	}
+	if _, err := (BranchAndBound{}).Search(weightedCircuit{}, phases); !errors.Is(err, errTooManyPhases) {
+		t.Errorf("err = %v, want %v", err, errTooManyPhases)
+	}
+}
+
+func TestDeadlock(t *testing.T) {
+	// Without the first signal every amplifier reads its phase and then
+	// waits on the second input at 6 for a signal that never comes.
+	c := newChain(amplifierPool(feedbackExample1), []int{9, 8, 7, 6, 5}, true)
+	err := c.scheduler.Run()
+	var deadlock *intcode.DeadlockError
+	if !errors.As(err, &deadlock) {
+		t.Fatalf("err = %v, want a deadlock", err)
+	}
+	if len(deadlock.Machines) != 5 {
+		t.Fatalf("amplifiers = %v, want 5", deadlock.Machines)
+	}
+	for i, a := range deadlock.Machines {
+		want := intcode.MachineState{ID: i, Ip: 6, Status: intcode.WaitingInput}
+		if a != want {
+			t.Errorf("amplifier %d = %+v, want %+v", i, a, want)
+		}
+	}
+}
+
+func TestDeadlockWithoutFeedback(t *testing.T) {
+	// The feedback program wired in series: once E's output leaves the
+	// circuit A never hears back.
+	_, err := newChain(amplifierPool(feedbackExample1), []int{9, 8, 7, 6, 5}, false).signal(0)
+	var deadlock *intcode.DeadlockError
+	if !errors.As(err, &deadlock) {
+		t.Fatalf("err = %v, want a deadlock", err)
+	}
+	if a := deadlock.Machines[0]; a.Status != intcode.WaitingInput || a.Pending != 0 {
+		t.Errorf("amplifier A = %+v, want it waiting with nothing pending", a)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/dannyxd11/AoC2019/intcode"
+	log "github.com/sirupsen/logrus"
+)
+
+// tap records the signals an amplifier sends before passing them on, so the
+// last one is known even after the next amplifier has read it.
+type tap struct {
+	next    intcode.Output
+	signals []int
+}
+
+func (t *tap) Write(val int) error {
+	t.signals = append(t.signals, val)
+	if t.next == nil {
+		return nil
+	}
+	return t.next.Write(val)
+}
+
+// chain is a circuit of amplifiers run on one intcode.Scheduler, each one's
+// outputs feeding the input of the next. With feedback the last amplifier
+// feeds the first, otherwise its outputs leave the circuit. A deadlock is
+// reported as an *intcode.DeadlockError whose machine IDs are the amplifiers'
+// positions in the chain.
+type chain struct {
+	pool      *intcode.Pool
+	scheduler *intcode.Scheduler
+	last      *tap
+}
+
+// amplifierPool returns a pool of amplifiers running program. The searches
+// evaluate thousands of settings, and pooled amplifiers share the program's
+// memory instead of each copying it.
+func amplifierPool(program []int) *intcode.Pool {
+	m := intcode.New(program)
+	// The amplifiers have always treated immediate mode writes as positions.
+	m.WriteMode = intcode.LenientWrites
+	return intcode.NewPool(m)
+}
+
+func newChain(pool *intcode.Pool, phases []int, feedback bool) *chain {
+	c := &chain{pool: pool, scheduler: intcode.NewScheduler(), last: &tap{}}
+	for _, p := range phases {
+		m := pool.Get()
+		m.Input = intcode.NewQueue(p)
+		c.scheduler.Add(m)
+	}
+	n := c.scheduler.Len()
+	for id := 0; id+1 < n; id++ {
+		c.scheduler.Connect(id, id+1)
+	}
+	if n > 0 {
+		if feedback {
+			c.last.next = c.scheduler.Machine(0).Input.(*intcode.Queue)
+		}
+		c.scheduler.Machine(n - 1).Output = c.last
+	}
+	return c
+}
+
+// signal sends input into the first amplifier, runs the circuit until every
+// amplifier halts and returns the last signal the final amplifier produced.
+// The amplifiers go back to the pool afterwards, so a chain only signals once.
+func (c *chain) signal(input int) (int, error) {
+	if c.scheduler.Len() == 0 {
+		return input, nil
+	}
+	defer c.release()
+	c.scheduler.Machine(0).Input.(*intcode.Queue).Push(input)
+	err := c.scheduler.Run()
+	for id := 0; id < c.scheduler.Len(); id++ {
+		m := c.scheduler.Machine(id)
+		log.WithFields(log.Fields{
+			"amplifier": string(rune('A' + id%26)),
+			"ip":        m.Ip(),
+			"status":    m.Status(),
+			"steps":     m.Steps(),
+		}).Debug("Scheduled amplifier")
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if len(c.last.signals) == 0 {
+		return 0, fmt.Errorf("amplifier %c halted without producing a signal", rune('A'+(c.scheduler.Len()-1)%26))
+	}
+	return c.last.signals[len(c.last.signals)-1], nil
+}
+
+func (c *chain) release() {
+	for id := 0; id < c.scheduler.Len(); id++ {
+		c.pool.Put(c.scheduler.Machine(id))
+	}
+}
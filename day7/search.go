@@ -0,0 +1,299 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+)
+
+// Circuit produces the final signal of a chain of amplifiers for a phase setting.
+type Circuit interface {
+	Signal(phases []int) (int, error)
+}
+
+// StagedCircuit is a Circuit whose amplifiers can be run one at a time, so a
+// search can reuse the signal produced by a shared prefix of phases.
+type StagedCircuit interface {
+	Circuit
+	Stage(phase int, signal int) (int, error)
+}
+
+// SearchResult is the best phase setting a Strategy found. Optimal is only set
+// when the strategy has proven no other setting produces a higher signal.
+type SearchResult struct {
+	Phases      []int
+	Signal      int
+	Optimal     bool
+	Evaluations int
+}
+
+// Strategy searches the orderings of phases for the one producing the highest
+// signal. It stops at the first setting the circuit fails to evaluate.
+type Strategy interface {
+	Search(c Circuit, phases []int) (SearchResult, error)
+}
+
+var errTooManyPhases = errors.New("too many phases")
+
+func circuitError(phases []int, err error) error {
+	return fmt.Errorf("phase setting %v: %w", phases, err)
+}
+
+// Exhaustive tries every permutation of the phases. MaxEvaluations caps the
+// number of circuit runs, 0 means no limit.
+type Exhaustive struct {
+	MaxEvaluations int
+}
+
+func (s Exhaustive) Search(c Circuit, phases []int) (SearchResult, error) {
+	res := SearchResult{Signal: -1, Optimal: true}
+	var err error
+	permutations(append([]int(nil), phases...), func(perm []int) bool {
+		if s.MaxEvaluations > 0 && res.Evaluations >= s.MaxEvaluations {
+			res.Optimal = false
+			return false
+		}
+		signal, cerr := c.Signal(perm)
+		res.Evaluations++
+		if cerr != nil {
+			res.Optimal = false
+			err = circuitError(perm, cerr)
+			return false
+		}
+		if res.Phases == nil || signal > res.Signal {
+			res.Phases, res.Signal = append([]int(nil), perm...), signal
+		}
+		return true
+	})
+	return res, err
+}
+
+// BranchAndBound walks the phase orderings depth first. For a StagedCircuit the
+// output of every amplifier is memoised by (phase, input signal) and the best
+// completion of a partial chain by (phases left, signal), so chains sharing a
+// prefix or reconverging on the same signal are only explored once. Bound, if
+// set, returns an upper bound on the signal reachable from a partial chain and
+// is used to prune it; it must never underestimate. Circuits that cannot be
+// staged, such as feedback loops, are only evaluated on complete settings.
+// At most 64 phases are supported.
+type BranchAndBound struct {
+	Bound          func(remaining []int, signal int) int
+	MaxEvaluations int
+}
+
+type chainKey struct {
+	left   uint64
+	signal int
+}
+
+type chainBest struct {
+	signal int
+	suffix []int
+}
+
+type bnbSearch struct {
+	BranchAndBound
+	circuit Circuit
+	phases  []int
+	stages  map[[2]int]int
+	chains  map[chainKey]chainBest
+	best    SearchResult
+	cut     bool
+	err     error
+}
+
+func (s BranchAndBound) Search(c Circuit, phases []int) (SearchResult, error) {
+	if len(phases) > 64 {
+		return SearchResult{Signal: -1}, fmt.Errorf("%w: %d phases, at most 64 are supported", errTooManyPhases, len(phases))
+	}
+	b := &bnbSearch{
+		BranchAndBound: s,
+		circuit:        c,
+		phases:         phases,
+		stages:         map[[2]int]int{},
+		chains:         map[chainKey]chainBest{},
+		best:           SearchResult{Signal: -1},
+	}
+	all := uint64(1)<<uint(len(phases)) - 1
+	if len(phases) == 64 {
+		all = ^uint64(0)
+	}
+
+	if staged, ok := c.(StagedCircuit); ok {
+		if r, ok := b.staged(staged, all, 0, nil); ok {
+			b.best.Phases, b.best.Signal = r.suffix, r.signal
+		}
+	} else {
+		b.complete(all, nil)
+	}
+	b.best.Optimal = !b.cut && b.err == nil
+	return b.best, b.err
+}
+
+func (b *bnbSearch) exhausted() bool {
+	if b.err != nil {
+		return true
+	}
+	if b.MaxEvaluations > 0 && b.best.Evaluations >= b.MaxEvaluations {
+		b.cut = true
+		return true
+	}
+	return false
+}
+
+func (b *bnbSearch) remaining(left uint64) []int {
+	var rem []int
+	for i, p := range b.phases {
+		if left&(1<<uint(i)) != 0 {
+			rem = append(rem, p)
+		}
+	}
+	return rem
+}
+
+// staged returns the best completion of a chain that has produced signal and
+// still has the phases in left to place. chain is the prefix placed so far and
+// is only used to compare the bound against the best full setting.
+func (b *bnbSearch) staged(c StagedCircuit, left uint64, signal int, chain []int) (chainBest, bool) {
+	if left == 0 {
+		if b.best.Phases == nil || signal > b.best.Signal {
+			b.best.Phases = append([]int(nil), chain...)
+			b.best.Signal = signal
+		}
+		return chainBest{signal, nil}, true
+	}
+	key := chainKey{left, signal}
+	if r, ok := b.chains[key]; ok {
+		return r, true
+	}
+	if b.Bound != nil && b.best.Phases != nil && b.Bound(b.remaining(left), signal) <= b.best.Signal {
+		return chainBest{}, false
+	}
+
+	var best chainBest
+	found, pruned := false, false
+	for i, p := range b.phases {
+		bit := uint64(1) << uint(i)
+		if left&bit == 0 {
+			continue
+		}
+		out, ok := b.stages[[2]int{p, signal}]
+		if !ok {
+			if b.exhausted() {
+				pruned = true
+				break
+			}
+			var err error
+			out, err = c.Stage(p, signal)
+			b.best.Evaluations++
+			if err != nil {
+				b.err = circuitError(append(chain, p), err)
+				pruned = true
+				break
+			}
+			b.stages[[2]int{p, signal}] = out
+		}
+		r, ok := b.staged(c, left&^bit, out, append(chain, p))
+		if !ok {
+			pruned = true
+			continue
+		}
+		if !found || r.signal > best.signal {
+			best = chainBest{r.signal, append([]int{p}, r.suffix...)}
+			found = true
+		}
+	}
+	// A completion found with some branches pruned is only the best of what
+	// was explored, so it cannot be reused for another prefix.
+	if found && !pruned {
+		b.chains[key] = best
+	}
+	return best, found
+}
+
+func (b *bnbSearch) complete(left uint64, chain []int) {
+	if left == 0 {
+		if b.exhausted() {
+			return
+		}
+		signal, err := b.circuit.Signal(chain)
+		b.best.Evaluations++
+		if err != nil {
+			b.err = circuitError(chain, err)
+			return
+		}
+		if b.best.Phases == nil || signal > b.best.Signal {
+			b.best.Phases = append([]int(nil), chain...)
+			b.best.Signal = signal
+		}
+		return
+	}
+	if b.Bound != nil && b.best.Phases != nil && b.Bound(b.remaining(left), 0) <= b.best.Signal {
+		return
+	}
+	for i, p := range b.phases {
+		bit := uint64(1) << uint(i)
+		if left&bit != 0 {
+			b.complete(left&^bit, append(chain, p))
+		}
+	}
+}
+
+// LocalSearch hill climbs from random phase settings, moving to the best
+// setting reachable by swapping two amplifiers until none improves the signal.
+// It never proves optimality but scales to circuits far too large to enumerate.
+type LocalSearch struct {
+	Seed       int64
+	Restarts   int
+	Iterations int
+}
+
+func (s LocalSearch) Search(c Circuit, phases []int) (SearchResult, error) {
+	rng := rand.New(rand.NewSource(s.Seed))
+	restarts := s.Restarts
+	if restarts <= 0 {
+		restarts = 1
+	}
+
+	res := SearchResult{Signal: -1}
+	for r := 0; r < restarts; r++ {
+		current := append([]int(nil), phases...)
+		rng.Shuffle(len(current), func(i, j int) {
+			current[i], current[j] = current[j], current[i]
+		})
+		signal, err := c.Signal(current)
+		res.Evaluations++
+		if err != nil {
+			return res, circuitError(current, err)
+		}
+
+		for it := 0; s.Iterations <= 0 || it < s.Iterations; it++ {
+			bestI, bestJ, bestSignal := -1, -1, signal
+			for i := 0; i < len(current); i++ {
+				for j := i + 1; j < len(current); j++ {
+					current[i], current[j] = current[j], current[i]
+					v, err := c.Signal(current)
+					res.Evaluations++
+					if err != nil {
+						return res, circuitError(current, err)
+					}
+					if v > bestSignal {
+						bestI, bestJ, bestSignal = i, j, v
+					}
+					current[i], current[j] = current[j], current[i]
+				}
+			}
+			if bestI < 0 {
+				break
+			}
+			current[bestI], current[bestJ] = current[bestJ], current[bestI]
+			signal = bestSignal
+		}
+
+		if res.Phases == nil || signal > res.Signal {
+			res.Phases, res.Signal = append([]int(nil), current...), signal
+		}
+	}
+	res.Optimal = len(phases) <= 1
+	return res, nil
+}
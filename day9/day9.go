@@ -3,11 +3,11 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"github.com/dannyxd11/AoC2019/intcode"
 	log "github.com/sirupsen/logrus"
 	"io"
 	"os"
 	"strconv"
-	"strings"
 )
 
 func check(e error) {
@@ -250,21 +250,13 @@ func loadAndRun(file io.ReadSeeker, inputs []float64, mode string, ip int) ([]fl
 	_, err := file.Seek(0, io.SeekStart)
 	check(err)
 
-	scanner := bufio.NewScanner(file)
-
-	// Move to first line
-	scanner.Scan()
-
-	sProgram := strings.Split(scanner.Text(), ",")
+	values, err := intcode.ReadProgram(file)
+	check(err)
 
 	// Cast elements to float64
 	var program []float64
-	for _, i := range sProgram {
-		j, err := strconv.ParseFloat(i, 64)
-		if err != nil {
-			panic(err)
-		}
-		program = append(program, j)
+	for _, v := range values {
+		program = append(program, float64(v))
 	}
 
 	buffer := make([]float64, len(program)*10)
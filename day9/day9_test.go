@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dannyxd11/AoC2019/intcode"
+)
+
+var interpreter = intcode.Interpreter{
+	Name:     "day9",
+	Supports: ^intcode.Feature(0),
+	Run: func(program []int, inputs []int) (res intcode.Result) {
+		defer func() {
+			if r := recover(); r != nil {
+				res.Err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+
+		// Sized the same way as loadAndRun.
+		memory := make([]float64, len(program)*11)
+		for i, v := range program {
+			memory[i] = float64(v)
+		}
+		var fInputs []float64
+		for _, v := range inputs {
+			fInputs = append(fInputs, float64(v))
+		}
+
+		output, _, _, _ := execute(&memory, fInputs, "", 0, 0)
+		for _, v := range output {
+			res.Outputs = append(res.Outputs, int(v))
+		}
+		for _, v := range memory {
+			res.Memory = append(res.Memory, int(v))
+		}
+		return res
+	},
+}
+
+func TestDifferential(t *testing.T) {
+	cases, err := intcode.Corpus("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range intcode.Differential(cases, interpreter) {
+		t.Error(d)
+	}
+}
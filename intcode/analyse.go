@@ -0,0 +1,234 @@
+package intcode
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CodeWrite is a write by the instruction at Ip to Addr, a cell of the
+// instruction at Instruction.
+type CodeWrite struct {
+	Ip          int
+	Addr        int
+	Instruction int
+}
+
+// Report is the result of analysing a program without running it. Writes
+// through relative mode parameters could land anywhere, so a program that
+// makes any is never reported as safe.
+type Report struct {
+	// Complete is set if every instruction the program can run was found.
+	// Without it the rest of the report only covers the code that was.
+	Complete bool
+	// Written lists the addresses written through position mode parameters.
+	Written []int
+	// RelativeWrites is set if the program also writes through relative mode
+	// parameters, whose addresses are not known statically.
+	RelativeWrites bool
+	// CodeWrites lists the writes that land on the program's own code.
+	CodeWrites []CodeWrite
+	// InputCells lists the cells whose value can depend on input.
+	InputCells []int
+	// InputStack is set if input can also be written through relative mode
+	// parameters. Those cells are not known statically, so are missing from
+	// InputCells, and any cell read in position mode may hold input.
+	InputStack bool
+	// InputBranches lists the jumps whose condition depends on input, and
+	// InputAddresses the instructions whose code, addresses or jump target
+	// do.
+	InputBranches  []int
+	InputAddresses []int
+
+	// CompileSafe is set if compiled instructions never need to be thrown
+	// away because the program rewrote them.
+	CompileSafe bool
+	// SymbolicSafe is set if a symbolic run never has to split a path to
+	// pin an input-dependent instruction, address or jump target to a
+	// concrete value.
+	SymbolicSafe bool
+	// Reasons explains any safety flag that is not set.
+	Reasons []string
+}
+
+// Analyse follows the control and data flow of program from address 0.
+func Analyse(program []int) Report {
+	f := analyseFlow(program, 0)
+	r := Report{Complete: f.complete(), Written: sortedKeys(f.written)}
+
+	for _, addr := range f.addresses() {
+		in := f.instrs[addr]
+		for p, role := range in.op.instruction.Roles {
+			param := in.op.params[p]
+			if role != Write {
+				continue
+			}
+			if param.mode == relativeMode {
+				r.RelativeWrites = true
+			} else if param.mode == positionMode {
+				if code := f.code[param.val]; len(code) > 0 {
+					r.CodeWrites = append(r.CodeWrites, CodeWrite{addr, param.val, code[0]})
+				} else if f.isFault(param.val) {
+					r.CodeWrites = append(r.CodeWrites, CodeWrite{addr, param.val, param.val})
+				}
+			}
+		}
+	}
+
+	t := taint(f)
+	r.InputCells = sortedKeys(t.cells)
+	r.InputStack = t.stack
+	r.InputBranches = sortedKeys(t.branches)
+	r.InputAddresses = sortedKeys(t.addresses)
+
+	r.CompileSafe, r.SymbolicSafe = true, true
+	if !r.Complete {
+		r.CompileSafe, r.SymbolicSafe = false, false
+		if f.indirect {
+			r.Reasons = append(r.Reasons, "a jump target is computed at runtime, so not all code could be found")
+		}
+		if f.selfModifying {
+			r.Reasons = append(r.Reasons, "the program modifies its own code, so not all code could be found")
+		}
+	}
+	if len(r.CodeWrites) > 0 {
+		r.CompileSafe = false
+		r.Reasons = append(r.Reasons, fmt.Sprintf("%d writes land on code and invalidate compiled instructions", len(r.CodeWrites)))
+	}
+	if r.RelativeWrites {
+		r.CompileSafe, r.SymbolicSafe = false, false
+		r.Reasons = append(r.Reasons, "the program writes through relative mode parameters, which may land on code")
+	}
+	if len(r.InputAddresses) > 0 {
+		r.SymbolicSafe = false
+		r.Reasons = append(r.Reasons, fmt.Sprintf("%d instructions use input-dependent code, addresses or jump targets", len(r.InputAddresses)))
+	}
+	return r
+}
+
+func (f *flow) isFault(addr int) bool {
+	i := sort.SearchInts(f.faults, addr)
+	return i < len(f.faults) && f.faults[i] == addr
+}
+
+func sortedKeys(m map[int]bool) []int {
+	keys := []int{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// taints tracks which values can depend on input. Cells addressed in
+// relative mode are lumped together as the stack, which may overlap any cell
+// addressed in position mode.
+type taints struct {
+	cells     map[int]bool
+	stack     bool
+	base      bool // bp depends on input
+	branches  map[int]bool
+	addresses map[int]bool
+}
+
+// taint propagates input dependence through the reachable instructions until
+// nothing changes. It ignores the order instructions run in, so a cell is
+// marked if any path could leave input in it.
+func taint(f *flow) *taints {
+	t := &taints{cells: map[int]bool{}, branches: map[int]bool{}, addresses: map[int]bool{}}
+	addrs := f.addresses()
+
+	read := func(param Parameter) bool {
+		switch param.mode {
+		case positionMode:
+			return t.cells[param.val] || t.stack
+		case relativeMode:
+			return t.stack
+		}
+		return false
+	}
+	write := func(param Parameter, tainted bool) bool {
+		if !tainted {
+			return false
+		}
+		if param.mode == relativeMode && !t.stack {
+			t.stack = true
+			return true
+		}
+		if param.mode == positionMode && !t.cells[param.val] {
+			t.cells[param.val] = true
+			return true
+		}
+		return false
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, addr := range addrs {
+			in := f.instrs[addr]
+			op := in.op
+			for a := addr; a < addr+in.size(); a++ {
+				if t.cells[a] {
+					t.addresses[addr] = true
+				}
+			}
+			for _, param := range op.params {
+				if param.mode == relativeMode && t.base {
+					t.addresses[addr] = true
+				}
+			}
+
+			switch op.opcode {
+			case 1, 2, 7, 8:
+				changed = write(op.params[2], read(op.params[0]) || read(op.params[1])) || changed
+			case 3:
+				changed = write(op.params[0], true) || changed
+			case 5, 6:
+				if read(op.params[0]) {
+					t.branches[addr] = true
+				}
+				if read(op.params[1]) {
+					t.addresses[addr] = true
+				}
+			case 9:
+				if read(op.params[0]) && !t.base {
+					t.base, changed = true, true
+				}
+			}
+		}
+	}
+	// An instruction that would fault as loaded may be patched with input.
+	for _, addr := range f.faults {
+		if t.cells[addr] {
+			t.addresses[addr] = true
+		}
+	}
+	return t
+}
+
+func (r Report) String() string {
+	var b strings.Builder
+	yesNo := func(v bool) string {
+		if v {
+			return "yes"
+		}
+		return "no"
+	}
+	fmt.Fprintf(&b, "complete:         %s\n", yesNo(r.Complete))
+	fmt.Fprintf(&b, "written:          %v\n", r.Written)
+	fmt.Fprintf(&b, "relative writes:  %s\n", yesNo(r.RelativeWrites))
+	fmt.Fprintf(&b, "code writes:     ")
+	for _, w := range r.CodeWrites {
+		fmt.Fprintf(&b, " %d->%d(%d)", w.Ip, w.Addr, w.Instruction)
+	}
+	fmt.Fprintf(&b, "\ninput cells:      %v\n", r.InputCells)
+	fmt.Fprintf(&b, "input stack:      %s\n", yesNo(r.InputStack))
+	fmt.Fprintf(&b, "input branches:   %v\n", r.InputBranches)
+	fmt.Fprintf(&b, "input addresses:  %v\n", r.InputAddresses)
+	fmt.Fprintf(&b, "compile safe:     %s\n", yesNo(r.CompileSafe))
+	fmt.Fprintf(&b, "symbolic safe:    %s\n", yesNo(r.SymbolicSafe))
+	for _, reason := range r.Reasons {
+		fmt.Fprintf(&b, "  - %s\n", reason)
+	}
+	return b.String()
+}
@@ -0,0 +1,57 @@
+package intcode
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestAnalyse(t *testing.T) {
+	tests := []struct {
+		name           string
+		program        []int
+		codeWrites     []CodeWrite
+		inputCells     []int
+		inputStack     bool
+		inputBranches  []int
+		inputAddresses []int
+		compileSafe    bool
+		symbolicSafe   bool
+	}{
+		{"compare to 8", compare8, nil, []int{20, 21}, false, []int{6, 13}, []int{}, true, true},
+		{"echo", []int{3, 0, 4, 0, 99}, []CodeWrite{{0, 0, 0}}, []int{0}, false, []int{}, []int{0}, false, false},
+		{"amplifier", amplifier1, nil, []int{15, 16}, false, []int{}, []int{}, true, true},
+		{"multiply past halt", []int{2, 4, 4, 5, 99, 0}, nil, []int{}, false, []int{}, []int{}, true, true},
+		{"day2 example", []int{1, 9, 10, 3, 2, 3, 11, 0, 99, 30, 40, 50}, []CodeWrite{{0, 3, 0}, {4, 0, 0}}, []int{}, false, []int{}, []int{}, false, false},
+		// Patches the halt at 8 through bp.
+		{"relative code write", []int{109, 0, 21101, 5, 0, 8, 104, 7, 99}, nil, []int{}, false, []int{}, []int{}, false, false},
+		// Stores input at 50 through bp and branches on it. Cell 50 is only
+		// known to hold input as part of the stack.
+		{"relative input", []int{109, 50, 203, 0, 1006, 50, 10, 104, 1, 99, 104, 0, 99}, nil, []int{}, true, []int{4}, []int{}, false, false},
+	}
+	for _, tt := range tests {
+		r := Analyse(tt.program)
+		if fmt.Sprint(r.CodeWrites) != fmt.Sprint(tt.codeWrites) {
+			t.Errorf("%s: code writes = %v, want %v", tt.name, r.CodeWrites, tt.codeWrites)
+		}
+		if fmt.Sprint(r.InputCells) != fmt.Sprint(tt.inputCells) {
+			t.Errorf("%s: input cells = %v, want %v", tt.name, r.InputCells, tt.inputCells)
+		}
+		if r.InputStack != tt.inputStack {
+			t.Errorf("%s: input stack = %t, want %t", tt.name, r.InputStack, tt.inputStack)
+		}
+		if tt.inputStack && !strings.Contains(r.String(), "input stack:      yes") {
+			t.Errorf("%s: report does not mention the input stack:\n%v", tt.name, r)
+		}
+		if fmt.Sprint(r.InputBranches) != fmt.Sprint(tt.inputBranches) {
+			t.Errorf("%s: input branches = %v, want %v", tt.name, r.InputBranches, tt.inputBranches)
+		}
+		if fmt.Sprint(r.InputAddresses) != fmt.Sprint(tt.inputAddresses) {
+			t.Errorf("%s: input addresses = %v, want %v", tt.name, r.InputAddresses, tt.inputAddresses)
+		}
+		if r.CompileSafe != tt.compileSafe || r.SymbolicSafe != tt.symbolicSafe {
+			t.Errorf("%s: compile safe %t, symbolic safe %t, want %t and %t\n%v",
+				tt.name, r.CompileSafe, r.SymbolicSafe, tt.compileSafe, tt.symbolicSafe, r)
+		}
+	}
+}
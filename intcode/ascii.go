@@ -0,0 +1,82 @@
+package intcode
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// ASCIIInput feeds a machine lines of text read from r, one character code at
+// a time, with each line terminated by a newline (10). It reads the next line
+// only once the program has consumed the previous one, so it can be used
+// interactively.
+type ASCIIInput struct {
+	r       *bufio.Reader
+	pending []int
+}
+
+func NewASCIIInput(r io.Reader) *ASCIIInput {
+	return &ASCIIInput{r: bufio.NewReader(r)}
+}
+
+// Read returns io.EOF once r is exhausted and every line has been consumed.
+func (a *ASCIIInput) Read() (int, error) {
+	if len(a.pending) == 0 {
+		line, err := a.r.ReadString('\n')
+		if len(line) == 0 && err != nil {
+			return 0, err
+		}
+		a.Push(trimNewline(line))
+	}
+	val := a.pending[0]
+	a.pending = a.pending[1:]
+	return val, nil
+}
+
+// Push queues a line of text as if it had been typed.
+func (a *ASCIIInput) Push(line string) {
+	for _, c := range []byte(line) {
+		a.pending = append(a.pending, int(c))
+	}
+	a.pending = append(a.pending, '\n')
+}
+
+func trimNewline(line string) string {
+	if len(line) > 0 && line[len(line)-1] == '\n' {
+		line = line[:len(line)-1]
+	}
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+	return line
+}
+
+// ASCIIOutput renders the values a machine outputs to w. Values below 128 are
+// written as characters; anything else, e.g. a puzzle answer, is written as a
+// number on its own line.
+type ASCIIOutput struct {
+	w io.Writer
+	// atLineStart tracks whether a number needs a newline before it.
+	atLineStart bool
+}
+
+func NewASCIIOutput(w io.Writer) *ASCIIOutput {
+	return &ASCIIOutput{w: w, atLineStart: true}
+}
+
+func (a *ASCIIOutput) Write(val int) error {
+	var err error
+	if val >= 0 && val < 128 {
+		_, err = a.w.Write([]byte{byte(val)})
+		a.atLineStart = val == '\n'
+		return err
+	}
+	if !a.atLineStart {
+		if _, err = io.WriteString(a.w, "\n"); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(a.w, "%d\n", val)
+	a.atLineStart = true
+	return err
+}
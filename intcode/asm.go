@@ -0,0 +1,193 @@
+package intcode
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Instructions are written in mnemonic form as the instruction's name followed
+// by one operand per parameter:
+//
+//	#5   immediate mode, the value 5
+//	[5]  position mode, the cell at address 5 (a bare 5 also works)
+//	@5   relative mode, the cell at relative base + 5
+//
+// e.g. "add #1 [4] @-1". Operands may also be separated by commas.
+
+var ErrSyntax = errors.New("syntax error")
+
+// Assemble encodes an instruction written in mnemonic form.
+func (s *InstructionSet) Assemble(line string) ([]int, error) {
+	fields := strings.Fields(strings.NewReplacer(",", " ").Replace(line))
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%w: empty instruction", ErrSyntax)
+	}
+	opcode, in, ok := s.byName(fields[0])
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownOpcode, fields[0])
+	}
+	operands := fields[1:]
+	if len(operands) != len(in.Roles) {
+		return nil, fmt.Errorf("%w: %s takes %d operands, got %d", ErrSyntax, in.Name, len(in.Roles), len(operands))
+	}
+
+	code := []int{opcode}
+	scale := 100
+	for _, operand := range operands {
+		mode, val, err := parseOperand(operand)
+		if err != nil {
+			return nil, err
+		}
+		code[0] += mode * scale
+		scale *= 10
+		code = append(code, val)
+	}
+	return code, nil
+}
+
+func (s *InstructionSet) byName(name string) (int, Instruction, bool) {
+	for opcode, in := range s.instructions {
+		if strings.EqualFold(in.Name, name) {
+			return opcode, *in, true
+		}
+	}
+	return 0, Instruction{}, false
+}
+
+func parseOperand(operand string) (mode int, val int, err error) {
+	text := operand
+	switch {
+	case strings.HasPrefix(text, "#"):
+		mode, text = immediateMode, text[1:]
+	case strings.HasPrefix(text, "@"):
+		mode, text = relativeMode, text[1:]
+	case strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]"):
+		mode, text = positionMode, text[1:len(text)-1]
+	}
+	if val, err = strconv.Atoi(text); err != nil {
+		return 0, 0, fmt.Errorf("%w: operand %q", ErrSyntax, operand)
+	}
+	return mode, val, nil
+}
+
+func formatOperand(param Parameter) string {
+	switch param.mode {
+	case immediateMode:
+		return fmt.Sprintf("#%d", param.val)
+	case relativeMode:
+		return fmt.Sprintf("@%d", param.val)
+	default:
+		return fmt.Sprintf("[%d]", param.val)
+	}
+}
+
+// Disassemble returns the instruction at addr of memory in mnemonic form and
+// the number of cells it takes up. A cell that is not a valid instruction is
+// shown as data.
+func (s *InstructionSet) Disassemble(memory []int, addr int) (string, int) {
+	cell := func(a int) int {
+		if a >= 0 && a < len(memory) {
+			return memory[a]
+		}
+		return 0
+	}
+	op, err := s.decode(cell(addr))
+	if err != nil {
+		return fmt.Sprintf("data %d", cell(addr)), 1
+	}
+	text := []string{op.instruction.Name}
+	for p := range op.params {
+		op.params[p].val = cell(addr + 1 + p)
+		text = append(text, formatOperand(op.params[p]))
+	}
+	return strings.Join(text, " "), op.nParams + 1
+}
+
+// peekInstruction decodes the instruction at ip as fetch does, but peeks at
+// its cells so that a device mapped over them is left as it was.
+func (m *Machine) peekInstruction() (op Operation, instruction int, err error) {
+	if instruction, err = m.Peek(m.ip); err != nil {
+		return op, instruction, err
+	}
+	if op, err = m.InstructionSet().decode(instruction); err != nil {
+		return op, instruction, err
+	}
+	for p := range op.params {
+		if op.params[p].val, err = m.Peek(m.ip + 1 + p); err != nil {
+			return op, instruction, err
+		}
+	}
+	return op, instruction, nil
+}
+
+// Explain describes the instruction at ip with each operand resolved against
+// the current memory and relative base, e.g.
+//
+//	4: add [4]=7 @-1=bp-1=9=3 -> [4]
+func (m *Machine) Explain() string {
+	op, instruction, err := m.peekInstruction()
+	if err != nil {
+		return fmt.Sprintf("%d: %d (%v)", m.ip, instruction, err)
+	}
+	text := []string{op.instruction.Name}
+	for p, param := range op.params {
+		operand := formatOperand(param)
+		addr := param.val
+		if param.mode == relativeMode {
+			addr += m.bp
+			operand += fmt.Sprintf("=bp%+d=%d", param.val, addr)
+		}
+		if op.instruction.Roles[p] == Write {
+			text = append(text, "-> "+operand)
+			continue
+		}
+		if param.mode != immediateMode {
+			val, err := m.Peek(addr)
+			if err != nil {
+				operand += "=" + err.Error()
+			} else {
+				operand += fmt.Sprintf("=%d", val)
+			}
+		}
+		text = append(text, operand)
+	}
+	return fmt.Sprintf("%d: %s", m.ip, strings.Join(text, " "))
+}
+
+// AssembleSource assembles a program written one instruction per line. A line
+// may instead hold raw values in the comma separated format, and anything
+// after a ; is a comment. It returns the program along with the address each
+// line's code starts at, keyed by line number counted from 1.
+func (s *InstructionSet) AssembleSource(text string) ([]int, map[int]int, error) {
+	var program []int
+	lines := map[int]int{}
+	for i, line := range strings.Split(text, "\n") {
+		if c := strings.Index(line, ";"); c >= 0 {
+			line = line[:c]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		var code []int
+		var err error
+		if c := line[0]; c == '-' || (c >= '0' && c <= '9') {
+			code, err = ReadProgram(strings.NewReader(line))
+		} else {
+			code, err = s.Assemble(line)
+		}
+		if err != nil {
+			var pErr *ParseError
+			if errors.As(err, &pErr) {
+				err = pErr.Err
+			}
+			return nil, nil, &ParseError{Line: i + 1, Column: 1, Index: len(program), Text: line, Err: err}
+		}
+		lines[i+1] = len(program)
+		program = append(program, code...)
+	}
+	return program, lines, nil
+}
@@ -0,0 +1,67 @@
+package intcode
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAssemble(t *testing.T) {
+	tests := []struct {
+		text string
+		want []int
+	}{
+		{"add #1 [4] @-1", []int{20101, 1, 4, -1}},
+		{"mul 3, #2, [5]", []int{1002, 3, 2, 5}},
+		{"in @0", []int{203, 0}},
+		{"jz #0 #12", []int{1106, 0, 12}},
+		{"halt", []int{99}},
+	}
+	for _, tt := range tests {
+		got, err := Standard.Assemble(tt.text)
+		if err != nil {
+			t.Errorf("Assemble(%q): %v", tt.text, err)
+			continue
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("Assemble(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+		// Disassembling gives back the canonical form, which assembles to
+		// the same code.
+		text, size := Standard.Disassemble(got, 0)
+		again, err := Standard.Assemble(text)
+		if err != nil || fmt.Sprint(again) != fmt.Sprint(got) || size != len(got) {
+			t.Errorf("Disassemble(%v) = %q, %d which assembles to %v, %v", got, text, size, again, err)
+		}
+	}
+
+	for _, bad := range []string{"", "nop", "add #1", "out #x"} {
+		if _, err := Standard.Assemble(bad); err == nil {
+			t.Errorf("Assemble(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestAssembleSource(t *testing.T) {
+	source := `; echo one value
+in [6]
+out [6]   ; write it back
+
+halt
+0
+`
+	program, lines, err := Standard.AssembleSource(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(program) != "[3 6 4 6 99 0]" {
+		t.Errorf("program = %v", program)
+	}
+	if fmt.Sprint(lines) != "map[2:0 3:2 5:4 6:5]" {
+		t.Errorf("lines = %v", lines)
+	}
+
+	_, _, err = Standard.AssembleSource("in [6]\nout #x\n")
+	if pErr, ok := err.(*ParseError); !ok || pErr.Line != 2 || pErr.Index != 2 {
+		t.Errorf("got %v, want a ParseError on line 2 at value 2", err)
+	}
+}
@@ -0,0 +1,212 @@
+package intcode
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// The benchmarks run each day's puzzle on every engine. Alongside the usual
+// figures they report instr/s, the instructions executed per second, and
+// allocs/instr, the heap allocations made per instruction.
+
+func loadChallenge(b *testing.B, day string) []int {
+	file, err := os.Open(filepath.Join("..", day, "challenge.txt"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer file.Close()
+	program, err := ReadProgram(file)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return program
+}
+
+// benchEngines runs work on each engine, which returns the number of
+// instructions it executed.
+func benchEngines(b *testing.B, work func(b *testing.B, engine Engine) int) {
+	for _, engine := range Engines {
+		b.Run(engine.String(), func(b *testing.B) {
+			b.ReportAllocs()
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+			steps := 0
+			for i := 0; i < b.N; i++ {
+				steps += work(b, engine)
+			}
+			runtime.ReadMemStats(&after)
+			if steps > 0 {
+				b.ReportMetric(float64(steps)/b.Elapsed().Seconds(), "instr/s")
+				b.ReportMetric(float64(after.Mallocs-before.Mallocs)/float64(steps), "allocs/instr")
+			}
+		})
+	}
+}
+
+// benchRun runs program on engine with inputs until it halts.
+func benchRun(b *testing.B, engine Engine, program []int, inputs ...int) (*Machine, *Queue) {
+	m := New(program)
+	m.SetEngine(engine)
+	out := &Queue{}
+	m.Input, m.Output = NewQueue(inputs...), out
+	if err := m.Run(); err != nil {
+		b.Fatal(err)
+	}
+	if m.Status() != Halted {
+		b.Fatalf("status = %v, want %v", m.Status(), Halted)
+	}
+	return m, out
+}
+
+// BenchmarkDay2 searches every noun and verb for the part two target.
+func BenchmarkDay2(b *testing.B) {
+	program := loadChallenge(b, "day2")
+	benchEngines(b, func(b *testing.B, engine Engine) int {
+		steps, found := 0, false
+		for noun := 0; noun <= 99; noun++ {
+			for verb := 0; verb <= 99; verb++ {
+				program[1], program[2] = noun, verb
+				m, _ := benchRun(b, engine, program)
+				steps += m.Steps()
+				found = found || m.Memory()[0] == 19690720
+			}
+		}
+		if !found {
+			b.Fatal("no noun and verb produce 19690720")
+		}
+		return steps
+	})
+}
+
+// BenchmarkDay2Pooled is BenchmarkDay2 with machines taken from a Pool
+// instead of built from scratch for each pair.
+func BenchmarkDay2Pooled(b *testing.B) {
+	program := loadChallenge(b, "day2")
+	benchEngines(b, func(b *testing.B, engine Engine) int {
+		template := New(program)
+		template.SetEngine(engine)
+		pool := NewPool(template)
+		steps, found := 0, false
+		for noun := 0; noun <= 99; noun++ {
+			for verb := 0; verb <= 99; verb++ {
+				m := pool.Get()
+				if err := m.Poke(1, noun); err != nil {
+					b.Fatal(err)
+				}
+				if err := m.Poke(2, verb); err != nil {
+					b.Fatal(err)
+				}
+				if err := m.Run(); err != nil {
+					b.Fatal(err)
+				}
+				result, _ := m.Peek(0)
+				steps += m.Steps()
+				found = found || result == 19690720
+				pool.Put(m)
+			}
+		}
+		if !found {
+			b.Fatal("no noun and verb produce 19690720")
+		}
+		return steps
+	})
+}
+
+// BenchmarkDay5 runs the diagnostic program for both system IDs.
+func BenchmarkDay5(b *testing.B) {
+	program := loadChallenge(b, "day5")
+	benchEngines(b, func(b *testing.B, engine Engine) int {
+		steps := 0
+		for _, systemID := range []int{1, 5} {
+			m, out := benchRun(b, engine, program, systemID)
+			if out.Len() == 0 {
+				b.Fatalf("system %d output nothing", systemID)
+			}
+			steps += m.Steps()
+		}
+		return steps
+	})
+}
+
+// permutations calls f with every ordering of values, reusing one slice.
+func permutations(values []int, f func([]int)) {
+	var permute func(k int)
+	permute = func(k int) {
+		if k == len(values) {
+			f(values)
+			return
+		}
+		for i := k; i < len(values); i++ {
+			values[k], values[i] = values[i], values[k]
+			permute(k + 1)
+			values[k], values[i] = values[i], values[k]
+		}
+	}
+	permute(0)
+}
+
+// amplifiers runs one amplifier per phase on a Scheduler, each feeding the
+// next, with the last feeding back into the first, until they all halt. It
+// returns the last signal and the instructions executed.
+func amplifiers(b *testing.B, engine Engine, program []int, phases []int) (int, int) {
+	s := NewScheduler()
+	for _, phase := range phases {
+		m := New(program)
+		m.SetEngine(engine)
+		m.Input = NewQueue(phase)
+		s.Add(m)
+	}
+	for id := range phases {
+		s.Connect(id, (id+1)%len(phases))
+	}
+	signals := s.Machine(0).Input.(*Queue)
+	signals.Push(0)
+	if err := s.Run(); err != nil {
+		b.Fatalf("amplifiers %v: %v", phases, err)
+	}
+
+	steps := 0
+	for id := 0; id < s.Len(); id++ {
+		steps += s.Machine(id).Steps()
+	}
+	values := signals.Values()
+	return values[len(values)-1], steps
+}
+
+// BenchmarkDay7 searches every phase setting for both parts. The amplifiers
+// are always wired in a loop; in part one each halts after its first output,
+// so the signal fed back to the first goes unread.
+func BenchmarkDay7(b *testing.B) {
+	program := loadChallenge(b, "day7")
+	benchEngines(b, func(b *testing.B, engine Engine) int {
+		steps := 0
+		for _, phases := range [][]int{{0, 1, 2, 3, 4}, {5, 6, 7, 8, 9}} {
+			best := 0
+			permutations(phases, func(phases []int) {
+				signal, n := amplifiers(b, engine, program, phases)
+				steps += n
+				if signal > best {
+					best = signal
+				}
+			})
+			if best == 0 {
+				b.Fatalf("phases %v never produced a signal", phases)
+			}
+		}
+		return steps
+	})
+}
+
+// BenchmarkDay9 runs BOOST in sensor boost mode.
+func BenchmarkDay9(b *testing.B) {
+	program := loadChallenge(b, "day9")
+	benchEngines(b, func(b *testing.B, engine Engine) int {
+		m, out := benchRun(b, engine, program, 2)
+		if out.Len() != 1 {
+			b.Fatalf("output = %v, want the coordinates alone", out.Values())
+		}
+		return m.Steps()
+	})
+}
@@ -0,0 +1,232 @@
+package intcode
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strconv"
+)
+
+// An image is stored as:
+//
+//	magic     "INTC"
+//	version   1 byte
+//	flags     1 byte, see flagChecksum and flagMetadata
+//	metadata  entry point as a uvarint, then the name as a uvarint length
+//	          and its bytes; only present with flagMetadata
+//	count     number of words as a uvarint
+//	words     each word as a zigzag varint
+//	checksum  CRC-32 (IEEE) of everything before it, big endian; only
+//	          present with flagChecksum
+var imageMagic = []byte("INTC")
+
+const (
+	imageVersion = 1
+
+	flagChecksum = 1 << 0
+	flagMetadata = 1 << 1
+
+	maxNameLength = 1 << 16
+)
+
+var (
+	ErrNotImage      = errors.New("not an Intcode image")
+	ErrImageVersion  = errors.New("unsupported image version")
+	ErrBadImage      = errors.New("corrupt image")
+	ErrImageChecksum = errors.New("image checksum mismatch")
+)
+
+// Image is a program along with the metadata the binary format can carry.
+type Image struct {
+	Program []int
+	// Name is a label for the program, e.g. the puzzle it came from.
+	Name string
+	// Entry is the address execution starts at.
+	Entry int
+	// Checksum adds a CRC-32 of the image when it is written. When an image
+	// is read it says whether one was present and verified.
+	Checksum bool
+}
+
+// NewFromImage returns a machine ready to run img from its entry point.
+func NewFromImage(img Image) *Machine {
+	m := New(img.Program)
+	m.ip, m.origin.ip = img.Entry, img.Entry
+	return m
+}
+
+// WriteImage writes img in the binary format.
+func WriteImage(w io.Writer, img Image) error {
+	if img.Entry < 0 || (img.Entry > 0 && img.Entry >= len(img.Program)) {
+		return fmt.Errorf("%w: entry point %d outside program of %d values", ErrBadAddress, img.Entry, len(img.Program))
+	}
+
+	var buf bytes.Buffer
+	buf.Write(imageMagic)
+	flags := byte(0)
+	if img.Checksum {
+		flags |= flagChecksum
+	}
+	if img.Name != "" || img.Entry != 0 {
+		flags |= flagMetadata
+	}
+	buf.Write([]byte{imageVersion, flags})
+
+	word := make([]byte, binary.MaxVarintLen64)
+	putUvarint := func(x uint64) {
+		buf.Write(word[:binary.PutUvarint(word, x)])
+	}
+	if flags&flagMetadata != 0 {
+		putUvarint(uint64(img.Entry))
+		putUvarint(uint64(len(img.Name)))
+		buf.WriteString(img.Name)
+	}
+	putUvarint(uint64(len(img.Program)))
+	for _, v := range img.Program {
+		buf.Write(word[:binary.PutVarint(word, int64(v))])
+	}
+	if img.Checksum {
+		var sum [4]byte
+		binary.BigEndian.PutUint32(sum[:], crc32.ChecksumIEEE(buf.Bytes()))
+		buf.Write(sum[:])
+	}
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// hashingReader feeds every byte read through it to a hash.
+type hashingReader struct {
+	r *bufio.Reader
+	h hash.Hash32
+}
+
+func (h *hashingReader) ReadByte() (byte, error) {
+	b, err := h.r.ReadByte()
+	if err == nil {
+		h.h.Write([]byte{b})
+	}
+	return b, err
+}
+
+func (h *hashingReader) Read(p []byte) (int, error) {
+	n, err := h.r.Read(p)
+	h.h.Write(p[:n])
+	return n, err
+}
+
+// ReadImage reads an image in the binary format.
+func ReadImage(r io.Reader) (Image, error) {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	hr := &hashingReader{br, crc32.NewIEEE()}
+	var img Image
+
+	header := make([]byte, len(imageMagic)+2)
+	if _, err := io.ReadFull(hr, header); err != nil {
+		return img, fmt.Errorf("%w: %v", ErrNotImage, err)
+	}
+	if !bytes.Equal(header[:len(imageMagic)], imageMagic) {
+		return img, ErrNotImage
+	}
+	if version := header[len(imageMagic)]; version != imageVersion {
+		return img, fmt.Errorf("%w: %d", ErrImageVersion, version)
+	}
+	flags := header[len(imageMagic)+1]
+	if flags&^(flagChecksum|flagMetadata) != 0 {
+		return img, fmt.Errorf("%w: unknown flags %#x", ErrBadImage, flags)
+	}
+
+	readUvarint := func(what string, max uint64) (int, error) {
+		x, err := binary.ReadUvarint(hr)
+		if err != nil {
+			return 0, fmt.Errorf("%w: reading %s: %v", ErrBadImage, what, noEOF(err))
+		}
+		if x > max {
+			return 0, fmt.Errorf("%w: %s %d is too large", ErrBadImage, what, x)
+		}
+		return int(x), nil
+	}
+
+	var err error
+	if flags&flagMetadata != 0 {
+		if img.Entry, err = readUvarint("entry point", uint64(^uint(0)>>1)); err != nil {
+			return img, err
+		}
+		length, err := readUvarint("name length", maxNameLength)
+		if err != nil {
+			return img, err
+		}
+		name := make([]byte, length)
+		if _, err := io.ReadFull(hr, name); err != nil {
+			return img, fmt.Errorf("%w: reading name: %v", ErrBadImage, noEOF(err))
+		}
+		img.Name = string(name)
+	}
+
+	count, err := readUvarint("word count", uint64(^uint(0)>>1))
+	if err != nil {
+		return img, err
+	}
+	// Grow the program as words are read rather than trusting count.
+	for i := 0; i < count; i++ {
+		v, err := binary.ReadVarint(hr)
+		if err != nil {
+			return img, fmt.Errorf("%w: reading word %d of %d: %v", ErrBadImage, i, count, noEOF(err))
+		}
+		img.Program = append(img.Program, int(v))
+	}
+	if img.Entry != 0 && img.Entry >= len(img.Program) {
+		return img, fmt.Errorf("%w: entry point %d outside program of %d values", ErrBadImage, img.Entry, len(img.Program))
+	}
+
+	if flags&flagChecksum != 0 {
+		want := hr.h.Sum32()
+		var sum [4]byte
+		if _, err := io.ReadFull(br, sum[:]); err != nil {
+			return img, fmt.Errorf("%w: reading checksum: %v", ErrBadImage, noEOF(err))
+		}
+		if got := binary.BigEndian.Uint32(sum[:]); got != want {
+			return img, fmt.Errorf("%w: stored %08x, computed %08x", ErrImageChecksum, got, want)
+		}
+		img.Checksum = true
+	}
+	return img, nil
+}
+
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Load reads a program in either the binary or the text format.
+func Load(r io.Reader) (Image, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(len(imageMagic)); err == nil && bytes.Equal(magic, imageMagic) {
+		return ReadImage(br)
+	}
+	program, err := ReadProgram(br)
+	return Image{Program: program}, err
+}
+
+// WriteProgram writes program in the comma separated text format.
+func WriteProgram(w io.Writer, program []int) error {
+	bw := bufio.NewWriter(w)
+	for i, v := range program {
+		if i > 0 {
+			bw.WriteByte(',')
+		}
+		bw.WriteString(strconv.Itoa(v))
+	}
+	bw.WriteByte('\n')
+	return bw.Flush()
+}
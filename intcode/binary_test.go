@@ -0,0 +1,60 @@
+package intcode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestImageRoundTrip(t *testing.T) {
+	images := []Image{
+		{Program: quine},
+		{Program: []int{104, 1125899906842624, 99, -1 << 62}, Name: "large", Checksum: true},
+		{Program: []int{99, 3, 0, 4, 0, 99}, Entry: 1, Name: "day5 echo"},
+		{},
+	}
+	for _, img := range images {
+		var buf bytes.Buffer
+		if err := WriteImage(&buf, img); err != nil {
+			t.Fatal(err)
+		}
+		got, err := Load(&buf)
+		if err != nil {
+			t.Fatalf("%q: %v", img.Name, err)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(img) {
+			t.Errorf("read back %+v, want %+v", got, img)
+		}
+	}
+}
+
+func TestImageErrors(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteImage(&buf, Image{Program: quine, Checksum: true}); err != nil {
+		t.Fatal(err)
+	}
+	good := buf.Bytes()
+
+	corrupt := append([]byte(nil), good...)
+	corrupt[10] ^= 0x40
+	truncated := good[:len(good)/2]
+	version := append([]byte(nil), good...)
+	version[4] = 2
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"corrupt", corrupt, ErrImageChecksum},
+		{"truncated", truncated, ErrBadImage},
+		{"version", version, ErrImageVersion},
+		{"text", []byte("1,0,0,3,99"), ErrNotImage},
+	}
+	for _, tt := range tests {
+		if _, err := ReadImage(bytes.NewReader(tt.data)); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
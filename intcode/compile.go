@@ -0,0 +1,239 @@
+package intcode
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Engine selects how a Machine executes instructions.
+type Engine int
+
+const (
+	// Interpreted decodes every instruction each time it is executed.
+	Interpreted Engine = iota
+	// Compiled decodes each instruction once into a closure specialised for
+	// its parameter modes, and recompiles it if the program overwrites it.
+	Compiled
+)
+
+// Engines lists every available engine.
+var Engines = []Engine{Interpreted, Compiled}
+
+func (e Engine) String() string {
+	switch e {
+	case Interpreted:
+		return "interpreted"
+	case Compiled:
+		return "compiled"
+	default:
+		return fmt.Sprintf("Engine(%d)", int(e))
+	}
+}
+
+// SetEngine changes how m executes instructions from its next step on.
+func (m *Machine) SetEngine(e Engine) {
+	m.engine = e
+	m.code = nil
+}
+
+// invalidate drops the compiled instructions that cover addr. Only those
+// starting at most the longest instruction's parameter count before addr can.
+func (m *Machine) invalidate(addr int) {
+	if m.code == nil {
+		return
+	}
+	for a := addr - m.longestInstruction(); a <= addr; a++ {
+		if a >= 0 && a < len(m.code) {
+			m.code[a] = nil
+		}
+	}
+}
+
+// longestInstruction returns the most parameters an instruction m can run
+// has. It avoids InstructionSet so that Standard's handlers can refer to it.
+func (m *Machine) longestInstruction() int {
+	if m.set == nil {
+		return standardLongest
+	}
+	return m.set.longest
+}
+
+func (m *Machine) stepCompiled() (int, error) {
+	if m.ip >= len(m.code) {
+		m.code = append(m.code, make([]func() error, m.mem.size-len(m.code))...)
+	}
+	instruction := m.mem.get(m.ip)
+	if m.code[m.ip] == nil {
+		op, _, err := m.fetch()
+		if err != nil {
+			return instruction, err
+		}
+		m.code[m.ip] = m.compile(op)
+	}
+	return instruction, m.code[m.ip]()
+}
+
+func (m *Machine) loader(param Parameter) func() (int, error) {
+	switch param.mode {
+	case immediateMode:
+		val := param.val
+		return func() (int, error) { return val, nil }
+	case relativeMode:
+		offset := param.val
+		return func() (int, error) { return m.load(offset + m.bp) }
+	default:
+		addr := param.val
+		if _, _, mapped := m.device(addr); addr < 0 || mapped || len(m.observers) > 0 {
+			return func() (int, error) { return m.load(addr) }
+		}
+		return func() (int, error) { return m.mem.get(addr), nil }
+	}
+}
+
+func (m *Machine) storer(param Parameter) func(int) error {
+	switch param.mode {
+	case positionMode:
+		addr := param.val
+		return func(val int) error { return m.store(addr, val) }
+	case relativeMode:
+		offset := param.val
+		return func(val int) error { return m.store(offset+m.bp, val) }
+	default:
+		// An immediate mode parameter only gets here in LenientWrites mode,
+		// which treats it as a position.
+		addr := param.val
+		return func(val int) error { return m.store(addr, val) }
+	}
+}
+
+// compile turns the instruction at m.ip into a closure that behaves exactly
+// as execInstruction would for it.
+func (m *Machine) compile(op Operation) func() error {
+	if !op.instruction.standard {
+		return func() error { return m.execInstruction(op) }
+	}
+	exec := m.compileStandard(op)
+	if _, ok := immediateWrite(op); !ok {
+		return exec
+	}
+	// WriteMode is checked on every run so that it can be changed after the
+	// instruction has been compiled.
+	return func() error {
+		if err := m.checkWrites(op); err != nil {
+			return err
+		}
+		return exec()
+	}
+}
+
+func (m *Machine) compileStandard(op Operation) func() error {
+	next := m.ip + op.nParams + 1
+
+	binary := func(f func(a, b int) int) func() error {
+		loadA, loadB, store := m.loader(op.params[0]), m.loader(op.params[1]), m.storer(op.params[2])
+		return func() error {
+			a, err := loadA()
+			if err != nil {
+				return err
+			}
+			b, err := loadB()
+			if err != nil {
+				return err
+			}
+			if err := store(f(a, b)); err != nil {
+				return err
+			}
+			return m.jump(next)
+		}
+	}
+	jump := func(when func(int) bool) func() error {
+		loadA, loadB := m.loader(op.params[0]), m.loader(op.params[1])
+		return func() error {
+			a, err := loadA()
+			if err != nil {
+				return err
+			}
+			b, err := loadB()
+			if err != nil {
+				return err
+			}
+			if when(a) {
+				return m.jump(b)
+			}
+			return m.jump(next)
+		}
+	}
+
+	switch op.opcode {
+	case 1:
+		return binary(func(a, b int) int { return a + b })
+	case 2:
+		return binary(func(a, b int) int { return a * b })
+	case 3:
+		store := m.storer(op.params[0])
+		return func() error {
+			if m.Input == nil {
+				m.status = WaitingInput
+				return nil
+			}
+			input, err := m.Input.Read()
+			if errors.Is(err, ErrNoInput) {
+				m.status = WaitingInput
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if err := store(input); err != nil {
+				return err
+			}
+			return m.jump(next)
+		}
+	case 4:
+		load := m.loader(op.params[0])
+		return func() error {
+			a, err := load()
+			if err != nil {
+				return err
+			}
+			if m.Output != nil {
+				if err := m.Output.Write(a); err != nil {
+					return err
+				}
+			}
+			return m.jump(next)
+		}
+	case 5:
+		return jump(func(a int) bool { return a != 0 })
+	case 6:
+		return jump(func(a int) bool { return a == 0 })
+	case 7:
+		return binary(func(a, b int) int {
+			if a < b {
+				return 1
+			}
+			return 0
+		})
+	case 8:
+		return binary(func(a, b int) int {
+			if a == b {
+				return 1
+			}
+			return 0
+		})
+	case 9:
+		load := m.loader(op.params[0])
+		return func() error {
+			a, err := load()
+			if err != nil {
+				return err
+			}
+			m.bp += a
+			return m.jump(next)
+		}
+	default:
+		return func() error {
+			m.status = Halted
+			return nil
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package intcode
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Case is a program to run with a fixed set of inputs. Want and WantMemory, if
+// set, are the output and final memory published alongside the program in the
+// puzzle text.
+type Case struct {
+	Name       string
+	Program    []int
+	Inputs     []int
+	Want       []int
+	WantMemory []int
+}
+
+// Published examples from the puzzle descriptions.
+var examples = []Case{
+	{Name: "day2 example", Program: []int{1, 9, 10, 3, 2, 3, 11, 0, 99, 30, 40, 50}, WantMemory: []int{3500, 9, 10, 70, 2, 3, 11, 0, 99, 30, 40, 50}},
+	{Name: "day2 add", Program: []int{1, 0, 0, 0, 99}, WantMemory: []int{2, 0, 0, 0, 99}},
+	{Name: "day2 multiply", Program: []int{2, 3, 0, 3, 99}, WantMemory: []int{2, 3, 0, 6, 99}},
+	{Name: "day2 multiply past halt", Program: []int{2, 4, 4, 5, 99, 0}, WantMemory: []int{2, 4, 4, 5, 99, 9801}},
+	{Name: "day2 overwrite halt", Program: []int{1, 1, 1, 4, 99, 5, 6, 0, 99}, WantMemory: []int{30, 1, 1, 4, 2, 5, 6, 0, 99}},
+
+	{Name: "day5 echo", Program: []int{3, 0, 4, 0, 99}, Inputs: []int{42}, Want: []int{42}},
+	{Name: "day5 modes", Program: []int{1002, 4, 3, 4, 33}, WantMemory: []int{1002, 4, 3, 4, 99}},
+	{Name: "day5 negative", Program: []int{1101, 100, -1, 4, 0}, WantMemory: []int{1101, 100, -1, 4, 99}},
+	{Name: "day5 equal to 8 position", Program: []int{3, 9, 8, 9, 10, 9, 4, 9, 99, -1, 8}, Inputs: []int{8}, Want: []int{1}},
+	{Name: "day5 not equal to 8 position", Program: []int{3, 9, 8, 9, 10, 9, 4, 9, 99, -1, 8}, Inputs: []int{7}, Want: []int{0}},
+	{Name: "day5 less than 8 position", Program: []int{3, 9, 7, 9, 10, 9, 4, 9, 99, -1, 8}, Inputs: []int{5}, Want: []int{1}},
+	{Name: "day5 not less than 8 position", Program: []int{3, 9, 7, 9, 10, 9, 4, 9, 99, -1, 8}, Inputs: []int{8}, Want: []int{0}},
+	{Name: "day5 equal to 8 immediate", Program: []int{3, 3, 1108, -1, 8, 3, 4, 3, 99}, Inputs: []int{8}, Want: []int{1}},
+	{Name: "day5 not equal to 8 immediate", Program: []int{3, 3, 1108, -1, 8, 3, 4, 3, 99}, Inputs: []int{9}, Want: []int{0}},
+	{Name: "day5 less than 8 immediate", Program: []int{3, 3, 1107, -1, 8, 3, 4, 3, 99}, Inputs: []int{-3}, Want: []int{1}},
+	{Name: "day5 not less than 8 immediate", Program: []int{3, 3, 1107, -1, 8, 3, 4, 3, 99}, Inputs: []int{12}, Want: []int{0}},
+	{Name: "day5 jump zero position", Program: []int{3, 12, 6, 12, 15, 1, 13, 14, 13, 4, 13, 99, -1, 0, 1, 9}, Inputs: []int{0}, Want: []int{0}},
+	{Name: "day5 jump non-zero position", Program: []int{3, 12, 6, 12, 15, 1, 13, 14, 13, 4, 13, 99, -1, 0, 1, 9}, Inputs: []int{3}, Want: []int{1}},
+	{Name: "day5 jump zero immediate", Program: []int{3, 3, 1105, -1, 9, 1101, 0, 0, 12, 4, 12, 99, 1}, Inputs: []int{0}, Want: []int{0}},
+	{Name: "day5 jump non-zero immediate", Program: []int{3, 3, 1105, -1, 9, 1101, 0, 0, 12, 4, 12, 99, 1}, Inputs: []int{-7}, Want: []int{1}},
+	{Name: "day5 below 8", Program: compare8, Inputs: []int{7}, Want: []int{999}},
+	{Name: "day5 equal to 8", Program: compare8, Inputs: []int{8}, Want: []int{1000}},
+	{Name: "day5 above 8", Program: compare8, Inputs: []int{9}, Want: []int{1001}},
+
+	{Name: "day7 example 1 amplifier A", Program: amplifier1, Inputs: []int{4, 0}, Want: []int{4}},
+	{Name: "day7 example 1 amplifier E", Program: amplifier1, Inputs: []int{0, 4321}, Want: []int{43210}},
+	{Name: "day7 example 2 amplifier A", Program: amplifier2, Inputs: []int{0, 0}, Want: []int{5}},
+	{Name: "day7 example 3 amplifier A", Program: amplifier3, Inputs: []int{1, 0}, Want: []int{6}},
+
+	{Name: "day9 quine", Program: quine, Want: quine},
+	{Name: "day9 16 digit", Program: []int{1102, 34915192, 34915192, 7, 4, 7, 99, 0}, Want: []int{1219070632396864}},
+	{Name: "day9 large number", Program: []int{104, 1125899906842624, 99}, Want: []int{1125899906842624}},
+}
+
+var compare8 = []int{
+	3, 21, 1008, 21, 8, 20, 1005, 20, 22, 107, 8, 21, 20, 1006, 20, 31,
+	1106, 0, 36, 98, 0, 0, 1002, 21, 125, 20, 4, 20, 1105, 1, 46, 104,
+	999, 1105, 1, 46, 1101, 1000, 1, 20, 4, 20, 1105, 1, 46, 98, 99,
+}
+
+var amplifier1 = []int{3, 15, 3, 16, 1002, 16, 10, 16, 1, 16, 15, 15, 4, 15, 99, 0, 0}
+
+var amplifier2 = []int{
+	3, 23, 3, 24, 1002, 24, 10, 24, 1002, 23, -1, 23,
+	101, 5, 23, 23, 1, 24, 23, 23, 4, 23, 99, 0, 0,
+}
+
+var amplifier3 = []int{
+	3, 31, 3, 32, 1002, 32, 10, 32, 1001, 31, -2, 31, 1007, 31, 0, 33,
+	1002, 33, 7, 33, 1, 33, 31, 31, 1, 32, 31, 31, 4, 31, 99, 0, 0, 0,
+}
+
+var quine = []int{109, 1, 204, -1, 1001, 100, 1, 100, 1008, 100, 16, 101, 1006, 101, 0, 99}
+
+// Corpus returns the published examples along with each day's puzzle input,
+// read from the day folders under root, run the way the day runs it.
+func Corpus(root string) ([]Case, error) {
+	cases := append([]Case(nil), examples...)
+
+	load := func(day string) ([]int, error) {
+		file, err := os.Open(filepath.Join(root, day, "challenge.txt"))
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return ReadProgram(file)
+	}
+
+	day2, err := load("day2")
+	if err != nil {
+		return nil, err
+	}
+	day2 = append([]int(nil), day2...)
+	day2[1], day2[2] = 12, 2
+	cases = append(cases, Case{Name: "day2 puzzle", Program: day2})
+
+	day5, err := load("day5")
+	if err != nil {
+		return nil, err
+	}
+	cases = append(cases,
+		Case{Name: "day5 puzzle system 1", Program: day5, Inputs: []int{1}},
+		Case{Name: "day5 puzzle system 5", Program: day5, Inputs: []int{5}},
+	)
+
+	day7, err := load("day7")
+	if err != nil {
+		return nil, err
+	}
+	for phase := 0; phase <= 4; phase++ {
+		cases = append(cases, Case{
+			Name:    fmt.Sprintf("day7 puzzle phase %d", phase),
+			Program: day7,
+			Inputs:  []int{phase, 17},
+		})
+	}
+
+	day9, err := load("day9")
+	if err != nil {
+		return nil, err
+	}
+	cases = append(cases,
+		Case{Name: "day9 puzzle BOOST test", Program: day9, Inputs: []int{1}},
+		Case{Name: "day9 puzzle BOOST sensor", Program: day9, Inputs: []int{2}},
+	)
+	return cases, nil
+}
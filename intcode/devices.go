@@ -0,0 +1,269 @@
+package intcode
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// Device is a virtual peripheral. It exposes Size cells that the program can
+// load from and store to, either by mapping it into the machine's address
+// space with Map or by attaching it to a Bus.
+//
+// Loading may change the device, as taking a key press does. Peek returns
+// what Load would without changing anything, for debuggers to inspect.
+type Device interface {
+	Size() int
+	Load(offset int) (int, error)
+	Peek(offset int) (int, error)
+	Store(offset int, val int) error
+}
+
+var ErrReadOnly = errors.New("device cell is read only")
+
+type mapping struct {
+	base   int
+	device Device
+}
+
+// Map attaches d to the addresses base to base+d.Size()-1. Reads and writes
+// to those addresses go to the device instead of memory.
+func (m *Machine) Map(base int, d Device) error {
+	end := base + d.Size()
+	if base < 0 || d.Size() <= 0 {
+		return fmt.Errorf("%w: cannot map %d cells at %d", ErrBadAddress, d.Size(), base)
+	}
+	for _, other := range m.devices {
+		if base < other.base+other.device.Size() && other.base < end {
+			return fmt.Errorf("%w: %d to %d overlaps the device at %d", ErrBadAddress, base, end-1, other.base)
+		}
+	}
+	m.devices = append(m.devices, mapping{base, d})
+	// Compiled instructions may have baked in direct memory accesses.
+	m.code = nil
+	return nil
+}
+
+// device returns the device mapped over addr, if any.
+func (m *Machine) device(addr int) (Device, int, bool) {
+	for _, d := range m.devices {
+		if addr >= d.base && addr < d.base+d.device.Size() {
+			return d.device, addr - d.base, true
+		}
+	}
+	return nil, 0, false
+}
+
+// Clock is a single read only cell holding the number of instructions the
+// machine it was created for has executed.
+type Clock struct {
+	m *Machine
+}
+
+func NewClock(m *Machine) *Clock {
+	return &Clock{m}
+}
+
+func (c *Clock) Size() int {
+	return 1
+}
+
+func (c *Clock) Load(offset int) (int, error) {
+	return c.m.Steps(), nil
+}
+
+func (c *Clock) Peek(offset int) (int, error) {
+	return c.Load(offset)
+}
+
+func (c *Clock) Store(offset int, val int) error {
+	return ErrReadOnly
+}
+
+// Random is a single cell that reads as the next non-negative number from a
+// seeded source. Storing to it reseeds the source, so runs are repeatable.
+type Random struct {
+	src *rand.Rand
+	// next is drawn ahead of being loaded so that it can be peeked at.
+	next int
+}
+
+func NewRandom(seed int64) *Random {
+	r := &Random{src: rand.New(rand.NewSource(seed))}
+	r.next = int(r.src.Int31())
+	return r
+}
+
+func (r *Random) Size() int {
+	return 1
+}
+
+func (r *Random) Load(offset int) (int, error) {
+	val := r.next
+	r.next = int(r.src.Int31())
+	return val, nil
+}
+
+func (r *Random) Peek(offset int) (int, error) {
+	return r.next, nil
+}
+
+func (r *Random) Store(offset int, val int) error {
+	r.src.Seed(int64(val))
+	r.next = int(r.src.Int31())
+	return nil
+}
+
+// Framebuffer is a Width by Height grid of pixels laid out row by row.
+type Framebuffer struct {
+	Width, Height int
+	pixels        []int
+}
+
+func NewFramebuffer(width, height int) *Framebuffer {
+	return &Framebuffer{width, height, make([]int, width*height)}
+}
+
+func (f *Framebuffer) Size() int {
+	return len(f.pixels)
+}
+
+func (f *Framebuffer) Load(offset int) (int, error) {
+	return f.pixels[offset], nil
+}
+
+func (f *Framebuffer) Peek(offset int) (int, error) {
+	return f.Load(offset)
+}
+
+func (f *Framebuffer) Store(offset int, val int) error {
+	f.pixels[offset] = val
+	return nil
+}
+
+func (f *Framebuffer) Pixel(x, y int) int {
+	return f.pixels[y*f.Width+x]
+}
+
+// String draws the framebuffer with a '#' for every non-zero pixel.
+func (f *Framebuffer) String() string {
+	var b strings.Builder
+	for y := 0; y < f.Height; y++ {
+		for x := 0; x < f.Width; x++ {
+			if f.Pixel(x, y) != 0 {
+				b.WriteByte('#')
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Keyboard buffers key presses for the program. Cell 0 holds the number of
+// keys waiting and reading cell 1 takes the next one, or -1 if there is none.
+type Keyboard struct {
+	keys []int
+}
+
+func (k *Keyboard) Press(keys ...int) {
+	k.keys = append(k.keys, keys...)
+}
+
+func (k *Keyboard) Size() int {
+	return 2
+}
+
+func (k *Keyboard) Load(offset int) (int, error) {
+	key, err := k.Peek(offset)
+	if offset == 1 && len(k.keys) > 0 {
+		k.keys = k.keys[1:]
+	}
+	return key, err
+}
+
+func (k *Keyboard) Peek(offset int) (int, error) {
+	if offset == 0 {
+		return len(k.keys), nil
+	}
+	if len(k.keys) == 0 {
+		return -1, nil
+	}
+	return k.keys[0], nil
+}
+
+func (k *Keyboard) Store(offset int, val int) error {
+	return ErrReadOnly
+}
+
+// Bus lets a program reach devices through its input and output instructions
+// rather than through memory. Each transfer starts with the program writing a
+// port number and a cell offset. Writing a third value stores it in that cell
+// of the device; reading instead loads the cell. Port 0 is the host: the value
+// written after it is passed to Output, and reads that are not part of a
+// transfer come from Input.
+//
+// Devices are numbered from port 1 in the order they are attached.
+type Bus struct {
+	Input  Input
+	Output Output
+
+	devices []Device
+	pending []int // the port and offset written so far
+}
+
+// Attach connects d to the bus and returns its port.
+func (b *Bus) Attach(d Device) int {
+	b.devices = append(b.devices, d)
+	return len(b.devices)
+}
+
+func (b *Bus) port(port, offset int) (Device, error) {
+	if port < 1 || port > len(b.devices) {
+		return nil, fmt.Errorf("%w: no device on port %d", ErrBadAddress, port)
+	}
+	d := b.devices[port-1]
+	if offset < 0 || offset >= d.Size() {
+		return nil, fmt.Errorf("%w: offset %d on port %d", ErrBadAddress, offset, port)
+	}
+	return d, nil
+}
+
+func (b *Bus) Write(val int) error {
+	if len(b.pending) == 1 && b.pending[0] == 0 {
+		b.pending = nil
+		if b.Output == nil {
+			return nil
+		}
+		return b.Output.Write(val)
+	}
+	if len(b.pending) < 2 {
+		b.pending = append(b.pending, val)
+		return nil
+	}
+	port, offset := b.pending[0], b.pending[1]
+	b.pending = nil
+	d, err := b.port(port, offset)
+	if err != nil {
+		return err
+	}
+	return d.Store(offset, val)
+}
+
+func (b *Bus) Read() (int, error) {
+	if len(b.pending) == 2 {
+		port, offset := b.pending[0], b.pending[1]
+		b.pending = nil
+		d, err := b.port(port, offset)
+		if err != nil {
+			return 0, err
+		}
+		return d.Load(offset)
+	}
+	if b.Input == nil {
+		return 0, ErrNoInput
+	}
+	return b.Input.Read()
+}
@@ -0,0 +1,171 @@
+package intcode
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Feature is a part of the instruction set that a program relies on. Not every
+// interpreter in the repository implements all of them.
+type Feature uint
+
+const (
+	FeatureIO             Feature = 1 << iota // opcodes 3 and 4
+	FeatureJumps                              // opcodes 5 and 6
+	FeatureComparisons                        // opcodes 7 and 8
+	FeatureImmediate                          // parameter mode 1
+	FeatureRelative                           // opcode 9 and parameter mode 2
+	FeatureExtendedMemory                     // addresses past the end of the program
+)
+
+// Result is what an interpreter did with a Case. Err is nil if the program
+// halted normally.
+type Result struct {
+	Outputs []int
+	Memory  []int
+	Err     error
+}
+
+// Interpreter adapts one of the Intcode implementations for the differential
+// harness. Supports lists the features it implements; cases that use any
+// other feature are not run through it.
+//
+// Only the day2, day5 and day9 interpreters are independent of Machine. Day 7
+// runs its amplifiers on Machine, so its adapter checks no more than that the
+// amplifiers' set-up leaves results unchanged.
+type Interpreter struct {
+	Name     string
+	Supports Feature
+	Run      func(program []int, inputs []int) Result
+}
+
+var ErrNeedsInput = errors.New("program needs more input than the case provides")
+
+// Reference runs cases on Machine.
+var Reference = Interpreter{
+	Name:     "reference",
+	Supports: ^Feature(0),
+	Run: func(program []int, inputs []int) Result {
+		res, _ := runReference(program, inputs)
+		return res
+	},
+}
+
+// runReference runs program on a Machine and notes the features it used.
+func runReference(program []int, inputs []int) (Result, Feature) {
+	m := New(program)
+	out := &Queue{}
+	m.Input, m.Output = NewQueue(inputs...), out
+
+	var used Feature
+	var err error
+	for m.Status() != Halted {
+		if op, _, ferr := m.fetch(); ferr == nil {
+			used |= m.features(op, len(program))
+		}
+		if err = m.Step(); err != nil {
+			break
+		}
+		if m.Status() == WaitingInput {
+			err = &Error{m.ip, m.mem.get(m.ip), ErrNeedsInput}
+			break
+		}
+	}
+	return Result{out.Values(), m.Memory(), err}, used
+}
+
+func (m *Machine) features(op Operation, programLength int) Feature {
+	var used Feature
+	switch op.opcode {
+	case 3, 4:
+		used |= FeatureIO
+	case 5, 6:
+		used |= FeatureJumps
+	case 7, 8:
+		used |= FeatureComparisons
+	case 9:
+		used |= FeatureRelative
+	}
+	for _, p := range op.params {
+		addr := p.val
+		switch p.mode {
+		case immediateMode:
+			used |= FeatureImmediate
+			continue
+		case relativeMode:
+			used |= FeatureRelative
+			addr += m.bp
+		}
+		if addr >= programLength {
+			used |= FeatureExtendedMemory
+		}
+	}
+	return used
+}
+
+// Divergence is a case on which an interpreter disagreed with the reference,
+// or on which the reference disagreed with the published answer.
+type Divergence struct {
+	Case        string
+	Interpreter string
+	What        string
+	Want, Got   interface{}
+}
+
+func (d Divergence) String() string {
+	return fmt.Sprintf("%s: %s %s differs: want %v, got %v", d.Case, d.Interpreter, d.What, d.Want, d.Got)
+}
+
+// Differential runs every case through the reference Machine and through each
+// interpreter that supports the features the case uses, and reports where the
+// outputs, final memory or whether the run faulted differ. Interpreters are
+// all compared against the reference, so any two that disagree with each
+// other will disagree with it too.
+func Differential(cases []Case, interpreters ...Interpreter) []Divergence {
+	var divergences []Divergence
+	for _, c := range cases {
+		want, used := runReference(c.Program, c.Inputs)
+		if c.Want != nil && !equalMemory(c.Want, want.Outputs) {
+			divergences = append(divergences, Divergence{c.Name, Reference.Name, "outputs", c.Want, want.Outputs})
+		}
+		if c.WantMemory != nil && !equalMemory(c.WantMemory, want.Memory) {
+			divergences = append(divergences, Divergence{c.Name, Reference.Name, "memory", c.WantMemory, want.Memory})
+		}
+
+		for _, in := range interpreters {
+			if used&^in.Supports != 0 {
+				continue
+			}
+			got := in.Run(append([]int(nil), c.Program...), append([]int(nil), c.Inputs...))
+			if (want.Err == nil) != (got.Err == nil) {
+				divergences = append(divergences, Divergence{c.Name, in.Name, "error", want.Err, got.Err})
+				continue
+			}
+			if !equalMemory(want.Outputs, got.Outputs) {
+				divergences = append(divergences, Divergence{c.Name, in.Name, "outputs", want.Outputs, got.Outputs})
+			}
+			if want.Err == nil && !equalMemory(want.Memory, got.Memory) {
+				divergences = append(divergences, Divergence{c.Name, in.Name, "memory", want.Memory, got.Memory})
+			}
+		}
+	}
+	return divergences
+}
+
+// equalMemory compares two slices treating missing cells as 0, since
+// interpreters size memory differently.
+func equalMemory(a, b []int) bool {
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	for i, v := range a {
+		if i < len(b) {
+			if b[i] != v {
+				return false
+			}
+		} else if v != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,199 @@
+package intcode
+
+import "sort"
+
+// staticInstr is an instruction found by following control flow from the
+// entry point of a program without running it.
+type staticInstr struct {
+	addr int
+	op   Operation
+	// succ lists the addresses control can move to next, as far as they are
+	// known.
+	succ []int
+}
+
+func (in *staticInstr) size() int {
+	return in.op.nParams + 1
+}
+
+// flow is what can be learnt about a program by following its control flow
+// from the entry point. The address of a relative mode parameter is not known
+// without running the program, so if any reachable instruction writes through
+// one, every cell is treated as possibly written.
+type flow struct {
+	program []int
+	instrs  map[int]*staticInstr
+	// code maps every cell of a reachable instruction to the instructions
+	// that cover it.
+	code map[int][]int
+	// written and read are the addresses reachable instructions write and
+	// read through position mode parameters.
+	written map[int]bool
+	read    map[int]bool
+	// indirect is set if a jump target could not be determined, so some
+	// reachable code may not have been found.
+	indirect bool
+	// relative is set if any reachable instruction addresses memory in
+	// relative mode, and relativeWrites if any writes through it.
+	relative       bool
+	relativeWrites bool
+	// selfModifying is set if a reachable instruction writes to the cells of
+	// another, so the code found may not be the code that runs.
+	selfModifying bool
+	// faults lists the reachable addresses that do not decode.
+	faults []int
+}
+
+func analyseFlow(program []int, entry int) *flow {
+	f := &flow{
+		program: program,
+		instrs:  map[int]*staticInstr{},
+		code:    map[int][]int{},
+		written: map[int]bool{},
+		read:    map[int]bool{},
+	}
+	cell := func(addr int) int {
+		if addr >= 0 && addr < len(program) {
+			return program[addr]
+		}
+		return 0
+	}
+
+	// jumpTargets are the position mode cells holding jump targets, which
+	// are only known if nothing writes to them.
+	var jumpTargets []int
+	work := []int{entry}
+	for len(work) > 0 {
+		addr := work[len(work)-1]
+		work = work[:len(work)-1]
+		if _, seen := f.instrs[addr]; seen {
+			continue
+		}
+		op, err := parseOp(cell(addr))
+		if err != nil || addr < 0 || addr >= len(program) {
+			f.faults = append(f.faults, addr)
+			continue
+		}
+		for p := range op.params {
+			op.params[p].val = cell(addr + 1 + p)
+		}
+		in := &staticInstr{addr: addr, op: op}
+		f.instrs[addr] = in
+		for a := addr; a < addr+in.size(); a++ {
+			f.code[a] = append(f.code[a], addr)
+		}
+
+		for p, role := range op.instruction.Roles {
+			param := op.params[p]
+			if param.mode == relativeMode {
+				f.relative = true
+				f.relativeWrites = f.relativeWrites || role == Write
+			} else if param.mode == positionMode && role == Write {
+				f.written[param.val] = true
+			} else if param.mode == positionMode {
+				f.read[param.val] = true
+			}
+		}
+
+		next := addr + in.size()
+		switch op.opcode {
+		case 99:
+		case 5, 6:
+			cond, target := op.params[0], op.params[1]
+			always, never := false, false
+			if cond.mode == immediateMode {
+				taken := (cond.val != 0) == (op.opcode == 5)
+				always, never = taken, !taken
+			}
+			if !always {
+				in.succ = append(in.succ, next)
+			}
+			if !never {
+				if target.mode == immediateMode {
+					in.succ = append(in.succ, target.val)
+				} else if target.mode == positionMode {
+					in.succ = append(in.succ, cell(target.val))
+					jumpTargets = append(jumpTargets, target.val)
+				} else {
+					f.indirect = true
+				}
+			}
+		default:
+			in.succ = append(in.succ, next)
+		}
+		work = append(work, in.succ...)
+	}
+
+	for _, addr := range jumpTargets {
+		if f.mayWrite(addr) {
+			f.indirect = true
+		}
+	}
+	for addr := range f.written {
+		if len(f.code[addr]) > 0 {
+			f.selfModifying = true
+		}
+	}
+	// A program can also patch an instruction that would fault into one that
+	// does not before reaching it.
+	for _, addr := range f.faults {
+		if f.written[addr] {
+			f.selfModifying = true
+		}
+	}
+	sort.Ints(f.faults)
+	return f
+}
+
+// complete reports whether every instruction the program can run was found.
+func (f *flow) complete() bool {
+	return !f.indirect && !f.selfModifying
+}
+
+// addresses returns the addresses of the reachable instructions in order.
+func (f *flow) addresses() []int {
+	var addrs []int
+	for addr := range f.instrs {
+		addrs = append(addrs, addr)
+	}
+	sort.Ints(addrs)
+	return addrs
+}
+
+// mayWrite reports whether a reachable instruction could write to addr.
+func (f *flow) mayWrite(addr int) bool {
+	return f.relativeWrites || f.written[addr]
+}
+
+// constant reports whether addr holds the same value for the whole run.
+func (f *flow) constant(addr int) bool {
+	return !f.mayWrite(addr)
+}
+
+// stable reports whether the cells of the instruction at addr are never
+// written or read as data, so that it can be rewritten without changing what
+// the program does.
+func (f *flow) stable(addr int) bool {
+	in := f.instrs[addr]
+	for a := addr; a < addr+in.size(); a++ {
+		if f.mayWrite(a) || f.read[a] || len(f.code[a]) > 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// value returns the value of a read parameter if it is known statically.
+func (f *flow) value(param Parameter) (int, bool) {
+	if param.mode == immediateMode {
+		return param.val, true
+	}
+	if param.mode == positionMode && f.constant(param.val) {
+		if param.val >= 0 && param.val < len(f.program) {
+			return f.program[param.val], true
+		} else if param.val >= 0 {
+			return 0, true
+		}
+	}
+	return 0, false
+}
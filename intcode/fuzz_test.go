@@ -0,0 +1,128 @@
+package intcode
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const (
+	fuzzMaxProgram = 256
+	fuzzMaxMemory  = 1 << 16
+	fuzzMaxSteps   = 10000
+)
+
+// decodeInts reads zigzag varints from data, so that the fuzzer can produce
+// small positive and negative values from a handful of bytes.
+func decodeInts(data []byte, limit int) []int {
+	var vals []int
+	for len(data) > 0 && len(vals) < limit {
+		v, n := binary.Varint(data)
+		if n <= 0 {
+			break
+		}
+		vals = append(vals, int(v))
+		data = data[n:]
+	}
+	return vals
+}
+
+func encodeInts(vals []int) []byte {
+	var data []byte
+	buf := make([]byte, binary.MaxVarintLen64)
+	for _, v := range vals {
+		n := binary.PutVarint(buf, int64(v))
+		data = append(data, buf[:n]...)
+	}
+	return data
+}
+
+// writesImmediate reports whether the instruction at m's ip has a parameter
+// that it writes to in immediate mode.
+func writesImmediate(m *Machine) bool {
+	op, _, err := m.fetch()
+	if err != nil {
+		return false
+	}
+	_, ok := immediateWrite(op)
+	return ok
+}
+
+func FuzzMachine(f *testing.F) {
+	for _, c := range examples {
+		f.Add(encodeInts(c.Program), encodeInts(c.Inputs))
+	}
+	f.Add(encodeInts([]int{1101, 1, 2, 0, 99}), []byte{})
+	f.Add(encodeInts([]int{3, 3, 1105, -1, 9, 1101, 0, 0, 12, 4, 12, 99, 1}), encodeInts([]int{0}))
+	// Writing far past the end of memory used to try to allocate all of it.
+	f.Add(encodeInts([]int{3, 1 << 40, 99}), encodeInts([]int{7}))
+
+	f.Fuzz(func(t *testing.T, programData []byte, inputData []byte) {
+		program := decodeInts(programData, fuzzMaxProgram)
+		inputs := decodeInts(inputData, fuzzMaxProgram)
+		if len(program) == 0 {
+			return
+		}
+
+		var machines []*Machine
+		var outputs []*Queue
+		for _, e := range Engines {
+			m := New(program)
+			m.SetEngine(e)
+			m.MemoryLimit = fuzzMaxMemory
+			out := &Queue{}
+			m.Input, m.Output = NewQueue(inputs...), out
+			machines = append(machines, m)
+			outputs = append(outputs, out)
+		}
+
+		// Step every engine in lockstep, checking the invariants after each
+		// instruction and that the engines agree with the first.
+		for step := 0; step < fuzzMaxSteps; step++ {
+			var errs []error
+			for _, m := range machines {
+				immediate := writesImmediate(m)
+
+				err := m.Step()
+				errs = append(errs, err)
+				if immediate && !errors.Is(err, ErrImmediateWrite) {
+					t.Fatalf("%v engine executed an immediate mode write at ip %d: %v", m.engine, m.Ip(), err)
+				}
+				if err != nil {
+					var mErr *Error
+					if !errors.As(err, &mErr) {
+						t.Fatalf("%v engine returned %T, not *Error: %v", m.engine, err, err)
+					}
+					continue
+				}
+
+				if m.Status() != Halted && (m.Ip() < 0 || m.Ip() >= len(m.Memory())) {
+					t.Fatalf("%v engine: ip %d outside memory of %d cells", m.engine, m.Ip(), len(m.Memory()))
+				}
+			}
+
+			ref := machines[0]
+			for i, m := range machines[1:] {
+				if (errs[0] == nil) != (errs[i+1] == nil) {
+					t.Fatalf("%v engine error %v, %v engine error %v", ref.engine, errs[0], m.engine, errs[i+1])
+				}
+				if m.Ip() != ref.Ip() || m.RelativeBase() != ref.RelativeBase() || m.Status() != ref.Status() {
+					t.Fatalf("%v engine at ip %d bp %d %v, %v engine at ip %d bp %d %v",
+						ref.engine, ref.Ip(), ref.RelativeBase(), ref.Status(),
+						m.engine, m.Ip(), m.RelativeBase(), m.Status())
+				}
+				if !reflect.DeepEqual(outputs[0].Values(), outputs[i+1].Values()) {
+					t.Fatalf("%v engine output %v, %v engine output %v", ref.engine, outputs[0].Values(), m.engine, outputs[i+1].Values())
+				}
+				if !equalMemory(ref.Memory(), m.Memory()) {
+					t.Fatalf("%v and %v engines' memory differs", ref.engine, m.engine)
+				}
+			}
+
+			if errs[0] != nil || ref.Status() != Running {
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,33 @@
+// Package intcode holds the pieces of the Intcode computer shared between the
+// days that need one.
+package intcode
+
+import "errors"
+
+const (
+	positionMode  = 0
+	immediateMode = 1
+	relativeMode  = 2
+)
+
+var (
+	ErrUnknownOpcode = errors.New("unrecognised opcode")
+	ErrUnknownMode   = errors.New("unrecognised mode")
+)
+
+type Parameter struct {
+	val  int
+	mode int
+}
+
+type Operation struct {
+	opcode      int
+	params      []Parameter
+	nParams     int
+	instruction *Instruction
+}
+
+// parseOp decodes an instruction from the Standard instruction set.
+func parseOp(instruction int) (Operation, error) {
+	return Standard.decode(instruction)
+}
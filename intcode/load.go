@@ -0,0 +1,148 @@
+package intcode
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParseError is a problem with the value starting at Line and Column (both
+// counted from 1) of a program's text. Index is the number of values read
+// before it.
+type ParseError struct {
+	Line, Column int
+	Index        int
+	Text         string
+	Err          error
+}
+
+func (e *ParseError) Error() string {
+	if e.Text == "" {
+		return fmt.Sprintf("line %d, column %d (value %d): %v", e.Line, e.Column, e.Index, e.Err)
+	}
+	return fmt.Sprintf("line %d, column %d (value %d) %q: %v", e.Line, e.Column, e.Index, e.Text, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+var ErrMissingValue = errors.New("expected a value")
+
+// ReadProgram reads a program from r. Values are separated by commas, by
+// whitespace and newlines, or by both, and a # starts a comment that runs to
+// the end of the line. There is no limit on the length of a program or of a
+// line.
+func ReadProgram(r io.Reader) ([]int, error) {
+	s := &programScanner{r: bufio.NewReader(r), line: 1}
+	var program []int
+	// separated is set once a comma has been read since the last value.
+	separated := false
+	for {
+		c, err := s.skip()
+		if err == io.EOF {
+			if separated && len(program) > 0 {
+				return nil, s.errorf(len(program), "", ErrMissingValue)
+			}
+			return program, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		if c == ',' {
+			if separated || len(program) == 0 {
+				return nil, s.errorf(len(program), "", ErrMissingValue)
+			}
+			s.next()
+			separated = true
+			continue
+		}
+
+		line, col := s.line, s.col+1
+		text, err := s.token()
+		if err != nil {
+			return nil, err
+		}
+		val, err := strconv.Atoi(text)
+		if err != nil {
+			if numErr, ok := err.(*strconv.NumError); ok {
+				err = numErr.Err
+			}
+			return nil, &ParseError{line, col, len(program), text, err}
+		}
+		program = append(program, val)
+		separated = false
+	}
+}
+
+// programScanner reads a program a byte at a time, keeping track of the
+// position of the next byte for error messages.
+type programScanner struct {
+	r         *bufio.Reader
+	line, col int
+}
+
+func (s *programScanner) errorf(index int, text string, err error) error {
+	return &ParseError{s.line, s.col + 1, index, text, err}
+}
+
+func (s *programScanner) peek() (byte, error) {
+	b, err := s.r.Peek(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (s *programScanner) next() {
+	c, _ := s.r.ReadByte()
+	if c == '\n' {
+		s.line, s.col = s.line+1, 0
+	} else {
+		s.col++
+	}
+}
+
+// skip moves past whitespace and comments, returning the next byte.
+func (s *programScanner) skip() (byte, error) {
+	for {
+		c, err := s.peek()
+		if err != nil {
+			return 0, err
+		}
+		if c == '#' {
+			for c != '\n' {
+				s.next()
+				if c, err = s.peek(); err != nil {
+					return 0, err
+				}
+			}
+		}
+		if !isSpace(c) {
+			return c, nil
+		}
+		s.next()
+	}
+}
+
+// token reads up to the next separator or comment.
+func (s *programScanner) token() (string, error) {
+	var b strings.Builder
+	for {
+		c, err := s.peek()
+		if err == io.EOF || (err == nil && (c == ',' || c == '#' || isSpace(c))) {
+			return b.String(), nil
+		} else if err != nil {
+			return "", err
+		}
+		b.WriteByte(c)
+		s.next()
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
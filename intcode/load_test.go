@@ -0,0 +1,79 @@
+package intcode
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestReadProgram(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []int
+	}{
+		{"single line", "1,0,0,3,99", []int{1, 0, 0, 3, 99}},
+		{"trailing newline", "1,0,0,3,99\n", []int{1, 0, 0, 3, 99}},
+		{"spaces", " 1, 0 ,0,\t3 , 99 \r\n", []int{1, 0, 0, 3, 99}},
+		{"several lines", "1,0,0,3,\n99\n", []int{1, 0, 0, 3, 99}},
+		{"whitespace separated", "1 0 0\n3 99", []int{1, 0, 0, 3, 99}},
+		{"comments", "# add\n1,0,0,3, # result in 3\n99 # halt", []int{1, 0, 0, 3, 99}},
+		{"negative", "1101,100,-1,4,0", []int{1101, 100, -1, 4, 0}},
+		{"empty", "# nothing\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadProgram(strings.NewReader(tt.text))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("ReadProgram(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadProgramLong(t *testing.T) {
+	// Longer than bufio.Scanner's default 64KB line limit.
+	values := make([]string, 100000)
+	for i := range values {
+		values[i] = strconv.Itoa(i)
+	}
+	got, err := ReadProgram(strings.NewReader(strings.Join(values, ",")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(values) || got[len(got)-1] != len(values)-1 {
+		t.Errorf("read %d values ending in %d, want %d", len(got), got[len(got)-1], len(values))
+	}
+}
+
+func TestReadProgramErrors(t *testing.T) {
+	tests := []struct {
+		text                string
+		line, column, index int
+		err                 error
+	}{
+		{"1,x,3", 1, 3, 1, strconv.ErrSyntax},
+		{"1,0,\n0,3.5", 2, 3, 3, strconv.ErrSyntax},
+		{"1,,3", 1, 3, 1, ErrMissingValue},
+		{",1", 1, 1, 0, ErrMissingValue},
+		{"1,2,", 1, 5, 2, ErrMissingValue},
+		{"1,99999999999999999999", 1, 3, 1, strconv.ErrRange},
+	}
+	for _, tt := range tests {
+		_, err := ReadProgram(strings.NewReader(tt.text))
+		var pErr *ParseError
+		if !errors.As(err, &pErr) || !errors.Is(err, tt.err) {
+			t.Errorf("ReadProgram(%q) returned %v, want a %v ParseError", tt.text, err, tt.err)
+			continue
+		}
+		if pErr.Line != tt.line || pErr.Column != tt.column || pErr.Index != tt.index {
+			t.Errorf("ReadProgram(%q) error at line %d column %d value %d, want line %d column %d value %d",
+				tt.text, pErr.Line, pErr.Column, pErr.Index, tt.line, tt.column, tt.index)
+		}
+	}
+}
@@ -0,0 +1,396 @@
+package intcode
+
+import (
+	"errors"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type Status int
+
+const (
+	Running Status = iota
+	WaitingInput
+	Halted
+)
+
+func (s Status) String() string {
+	switch s {
+	case Running:
+		return "running"
+	case WaitingInput:
+		return "waiting for input"
+	case Halted:
+		return "halted"
+	default:
+		return fmt.Sprintf("Status(%d)", int(s))
+	}
+}
+
+var (
+	ErrHalted     = errors.New("machine has halted")
+	ErrNoInput    = errors.New("no input available")
+	ErrBadAddress = errors.New("invalid address")
+	// ErrImmediateWrite is returned in StrictWrites mode for an instruction
+	// that would write through an immediate mode parameter.
+	ErrImmediateWrite = errors.New("write parameter in immediate mode")
+)
+
+// WriteMode decides what happens to an instruction that writes through an
+// immediate mode parameter, which the spec does not allow.
+type WriteMode int
+
+const (
+	// StrictWrites rejects the instruction before it has any effect.
+	StrictWrites WriteMode = iota
+	// LenientWrites treats the parameter as a position, as day 5 and day 7
+	// always did, so that legacy programs keep running.
+	LenientWrites
+)
+
+// Input supplies the values read by opcode 3. Returning ErrNoInput leaves the
+// machine waiting on the input instruction until it is run again.
+type Input interface {
+	Read() (int, error)
+}
+
+// Output receives the values written by opcode 4.
+type Output interface {
+	Write(val int) error
+}
+
+// Queue is a first in, first out list of values that can be used as both the
+// Input and the Output of a machine, e.g. to connect two machines together.
+type Queue struct {
+	values []int
+}
+
+func NewQueue(values ...int) *Queue {
+	return &Queue{append([]int(nil), values...)}
+}
+
+func (q *Queue) Read() (int, error) {
+	if len(q.values) == 0 {
+		return 0, ErrNoInput
+	}
+	val := q.values[0]
+	q.values = q.values[1:]
+	return val, nil
+}
+
+func (q *Queue) Write(val int) error {
+	q.values = append(q.values, val)
+	return nil
+}
+
+func (q *Queue) Push(values ...int) {
+	q.values = append(q.values, values...)
+}
+
+func (q *Queue) Len() int {
+	return len(q.values)
+}
+
+// Values returns the values still in the queue without removing them.
+func (q *Queue) Values() []int {
+	return q.values
+}
+
+// Error is a fault raised while executing the instruction at Ip.
+type Error struct {
+	Ip          int
+	Instruction int
+	Err         error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("ip %d (instruction %d): %v", e.Ip, e.Instruction, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Machine is an Intcode computer. Memory past the end of the program reads as
+// 0 and grows as it is written to. None of its methods panic on a bad program;
+// faults are returned as an *Error.
+type Machine struct {
+	mem     memory
+	origin  snapshot
+	ip      int
+	bp      int
+	steps   int
+	status  Status
+	engine  Engine
+	code    []func() error
+	devices []mapping
+	set     *InstructionSet
+
+	observers []Observer
+
+	Input  Input
+	Output Output
+	// MemoryLimit caps how many cells memory may grow to. Zero means
+	// DefaultMemoryLimit.
+	MemoryLimit int
+	WriteMode   WriteMode
+}
+
+// DefaultMemoryLimit is the most memory a machine will grow to unless its
+// MemoryLimit says otherwise.
+const DefaultMemoryLimit = 1 << 22
+
+// New returns a machine ready to run a copy of program.
+func New(program []int) *Machine {
+	m := &Machine{mem: newMemory(program)}
+	m.origin = m.snapshot()
+	return m
+}
+
+// SetInstructionSet changes the instructions m executes from its next step on.
+func (m *Machine) SetInstructionSet(s *InstructionSet) {
+	m.set = s
+	m.code = nil
+}
+
+// InstructionSet returns the instructions m executes, Standard unless
+// SetInstructionSet has been called.
+func (m *Machine) InstructionSet() *InstructionSet {
+	if m.set == nil {
+		return Standard
+	}
+	return m.set
+}
+
+// Memory returns a copy of the machine's memory.
+func (m *Machine) Memory() []int {
+	return m.mem.slice()
+}
+
+func (m *Machine) Ip() int {
+	return m.ip
+}
+
+func (m *Machine) RelativeBase() int {
+	return m.bp
+}
+
+func (m *Machine) Status() Status {
+	return m.status
+}
+
+// SetIp moves execution to ip, resuming a machine that had halted.
+func (m *Machine) SetIp(ip int) error {
+	return m.jump(ip)
+}
+
+func (m *Machine) SetRelativeBase(bp int) {
+	m.bp = bp
+}
+
+// Peek returns the value at addr, as the program would read it but without
+// changing a device mapped there.
+func (m *Machine) Peek(addr int) (int, error) {
+	if addr < 0 {
+		return 0, fmt.Errorf("%w: %d", ErrBadAddress, addr)
+	}
+	if d, offset, ok := m.device(addr); ok {
+		return d.Peek(offset)
+	}
+	return m.mem.get(addr), nil
+}
+
+// Poke stores val at addr, as the program would write it.
+func (m *Machine) Poke(addr int, val int) error {
+	return m.write(addr, val)
+}
+
+// Steps is the number of instructions executed so far.
+func (m *Machine) Steps() int {
+	return m.steps
+}
+
+func (m *Machine) read(addr int) (int, error) {
+	if addr < 0 {
+		return 0, fmt.Errorf("%w: %d", ErrBadAddress, addr)
+	}
+	if d, offset, ok := m.device(addr); ok {
+		return d.Load(offset)
+	}
+	return m.mem.get(addr), nil
+}
+
+func (m *Machine) write(addr int, val int) error {
+	if addr < 0 {
+		return fmt.Errorf("%w: %d", ErrBadAddress, addr)
+	}
+	if d, offset, ok := m.device(addr); ok {
+		return d.Store(offset, val)
+	}
+	if addr >= m.mem.size {
+		limit := m.MemoryLimit
+		if limit == 0 {
+			limit = DefaultMemoryLimit
+		}
+		if addr >= limit {
+			return fmt.Errorf("%w: %d is beyond the memory limit of %d", ErrBadAddress, addr, limit)
+		}
+	}
+	m.mem.set(addr, val)
+	m.invalidate(addr)
+	return nil
+}
+
+func (m *Machine) getVal(param Parameter) (int, error) {
+	if param.mode == positionMode {
+		return m.load(param.val)
+	} else if param.mode == immediateMode {
+		return param.val, nil
+	} else if param.mode == relativeMode {
+		return m.load(param.val + m.bp)
+	} else {
+		return 0, fmt.Errorf("%w: %d", ErrUnknownMode, param.mode)
+	}
+}
+
+func (m *Machine) setVal(param Parameter, val int) error {
+	if param.mode == relativeMode {
+		return m.store(param.val+m.bp, val)
+	}
+	return m.store(param.val, val)
+}
+
+// immediateWrite returns the index of the first parameter that op writes
+// through in immediate mode.
+func immediateWrite(op Operation) (int, bool) {
+	for p, role := range op.instruction.Roles {
+		if role == Write && op.params[p].mode == immediateMode {
+			return p, true
+		}
+	}
+	return 0, false
+}
+
+// checkWrites rejects op if it writes through an immediate mode parameter and
+// m is in StrictWrites mode.
+func (m *Machine) checkWrites(op Operation) error {
+	if p, ok := immediateWrite(op); ok && m.WriteMode == StrictWrites {
+		return fmt.Errorf("%w: parameter %d of %s", ErrImmediateWrite, p+1, op.instruction.Name)
+	}
+	return nil
+}
+
+// fetch decodes the instruction at ip along with its parameters.
+func (m *Machine) fetch() (op Operation, instruction int, err error) {
+	if instruction, err = m.read(m.ip); err != nil {
+		return op, instruction, err
+	}
+	if op, err = m.InstructionSet().decode(instruction); err != nil {
+		return op, instruction, err
+	}
+	for p := range op.params {
+		if op.params[p].val, err = m.read(m.ip + 1 + p); err != nil {
+			return op, instruction, err
+		}
+	}
+	return op, instruction, nil
+}
+
+func (m *Machine) execInstruction(op Operation) error {
+	if err := m.checkWrites(op); err != nil {
+		return err
+	}
+	args := &Args{m: m, params: op.params, vals: make([]int, op.nParams), next: m.ip + op.nParams + 1}
+	for p, role := range op.instruction.Roles {
+		if role != Read {
+			continue
+		}
+		val, err := m.getVal(op.params[p])
+		if err != nil {
+			return err
+		}
+		args.vals[p] = val
+	}
+
+	m.status = Running
+	if err := op.instruction.Exec(args); err != nil {
+		return err
+	}
+	if m.status != Running {
+		return nil
+	}
+	return m.jump(args.next)
+}
+
+// jump moves to the next instruction, which must lie within memory.
+func (m *Machine) jump(ip int) error {
+	if ip < 0 || ip >= m.mem.size {
+		return fmt.Errorf("%w: ip %d outside memory", ErrBadAddress, ip)
+	}
+	m.ip = ip
+	m.status = Running
+	return nil
+}
+
+// Step executes a single instruction. If the instruction is waiting for input
+// that is not yet available the machine is left on it with a WaitingInput
+// status and nil is returned.
+func (m *Machine) Step() error {
+	if m.status == Halted {
+		return ErrHalted
+	}
+
+	for _, o := range m.observers {
+		o.BeforeStep(m)
+	}
+	ip := m.ip
+
+	var instruction int
+	var err error
+	if m.engine == Compiled {
+		instruction, err = m.stepCompiled()
+	} else {
+		var op Operation
+		if op, instruction, err = m.fetch(); err == nil {
+			if log.IsLevelEnabled(log.TraceLevel) {
+				log.WithFields(log.Fields{"op": op, "ip": m.ip, "bp": m.bp}).Trace("Executing Operation")
+			}
+			err = m.execInstruction(op)
+		}
+	}
+	if err != nil {
+		err = &Error{m.ip, instruction, err}
+	} else if m.status != WaitingInput {
+		m.steps++
+	}
+
+	for _, o := range m.observers {
+		o.AfterStep(m, ip, err)
+		if err != nil {
+			continue
+		}
+		if m.status == WaitingInput {
+			o.OnWait(m)
+		} else if m.status == Halted {
+			o.OnHalt(m)
+		}
+	}
+	return err
+}
+
+// Run executes instructions until the machine halts, needs input that is not
+// available, or faults.
+func (m *Machine) Run() error {
+	if m.status == Halted {
+		return ErrHalted
+	}
+	for {
+		if err := m.Step(); err != nil {
+			return err
+		}
+		if m.status != Running {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package intcode
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// TestConformance runs the corpus, the published examples and each day's
+// puzzle, on every engine. The reference checks the published answers and
+// each engine must agree with it.
+func TestConformance(t *testing.T) {
+	cases, err := Corpus("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var engines []Interpreter
+	for _, engine := range Engines {
+		engine := engine
+		engines = append(engines, Interpreter{
+			Name:     engine.String(),
+			Supports: ^Feature(0),
+			Run: func(program []int, inputs []int) Result {
+				m := New(program)
+				m.SetEngine(engine)
+				out := &Queue{}
+				m.Input, m.Output = NewQueue(inputs...), out
+				err := m.Run()
+				if err == nil && m.Status() != Halted {
+					err = ErrNeedsInput
+				}
+				return Result{out.Values(), m.Memory(), err}
+			},
+		})
+	}
+	for _, d := range Differential(cases, engines...) {
+		t.Error(d)
+	}
+}
+
+func TestDevices(t *testing.T) {
+	// Copy the keyboard's next key to the first pixel and store the clock in
+	// the second.
+	program := []int{1, 1001, 100, 2000, 1, 1010, 100, 2001, 99}
+
+	for _, engine := range Engines {
+		m := New(program)
+		m.SetEngine(engine)
+		keyboard, screen := &Keyboard{}, NewFramebuffer(2, 1)
+		keyboard.Press('x')
+		for base, d := range map[int]Device{1000: keyboard, 1010: NewClock(m), 2000: screen} {
+			if err := m.Map(base, d); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := m.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if screen.Pixel(0, 0) != 'x' || screen.Pixel(1, 0) != 1 {
+			t.Errorf("%v: pixels = %d, %d, want %d, 1", engine, screen.Pixel(0, 0), screen.Pixel(1, 0), 'x')
+		}
+		if err := m.Map(1011, NewRandom(1)); err != nil {
+			t.Errorf("%v: mapping next to the clock: %v", engine, err)
+		}
+		if err := m.Map(2001, NewRandom(1)); err == nil {
+			t.Errorf("%v: mapping over the framebuffer succeeded", engine)
+		}
+	}
+}
+
+func TestPeekDevices(t *testing.T) {
+	// Add the keyboard's next key to the random number.
+	m := New([]int{1, 1001, 1010, 0, 99})
+	keyboard, random := &Keyboard{}, NewRandom(1)
+	keyboard.Press('a', 'b')
+	if err := m.Map(1000, keyboard); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Map(1010, random); err != nil {
+		t.Fatal(err)
+	}
+
+	// Looking, and explaining the add, must not take the key or draw the
+	// number.
+	want, _ := NewRandom(1).Load(0)
+	for i := 0; i < 2; i++ {
+		if key, _ := m.Peek(1001); key != 'a' {
+			t.Errorf("peek %d: key = %d, want %d", i, key, 'a')
+		}
+		if n, _ := m.Peek(1010); n != want {
+			t.Errorf("peek %d: random = %d, want %d", i, n, want)
+		}
+		m.Explain()
+	}
+
+	if err := m.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if sum, _ := m.Peek(0); sum != 'a'+want {
+		t.Errorf("sum = %d, want %d", sum, 'a'+want)
+	}
+	if key, _ := m.Peek(1001); key != 'b' {
+		t.Errorf("key after run = %d, want %d", key, 'b')
+	}
+}
+
+func TestBus(t *testing.T) {
+	// Store 7 in the framebuffer on port 1, load it back and send it to the
+	// host on port 0.
+	program := []int{104, 1, 104, 0, 104, 7, 104, 1, 104, 0, 3, 100, 104, 0, 4, 100, 99}
+	out := &Queue{}
+	bus := &Bus{Output: out}
+	screen := NewFramebuffer(1, 1)
+	if port := bus.Attach(screen); port != 1 {
+		t.Fatalf("port = %d, want 1", port)
+	}
+	m := New(program)
+	m.Input, m.Output = bus, bus
+	if err := m.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if screen.Pixel(0, 0) != 7 || fmt.Sprint(out.Values()) != "[7]" {
+		t.Errorf("pixel = %d, output = %v, want 7 and [7]", screen.Pixel(0, 0), out.Values())
+	}
+}
+
+func TestInstructionSet(t *testing.T) {
+	set := Standard.Clone()
+	err := set.Register(10, Instruction{Name: "double", Roles: []Role{Read, Write}, Exec: func(args *Args) error {
+		return args.Set(1, 2*args.Get(0))
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Register(1, Instruction{Exec: func(*Args) error { return nil }}); !errors.Is(err, ErrOpcodeTaken) {
+		t.Errorf("registering opcode 1 again: got %v, want %v", err, ErrOpcodeTaken)
+	}
+
+	program := []int{110, 21, 6, 4, 6, 99, 0}
+	for _, engine := range Engines {
+		m := New(program)
+		m.SetEngine(engine)
+		m.SetInstructionSet(set)
+		out := &Queue{}
+		m.Output = out
+		if err := m.Run(); err != nil {
+			t.Fatalf("%v: %v", engine, err)
+		}
+		if fmt.Sprint(out.Values()) != "[42]" {
+			t.Errorf("%v: output = %v, want [42]", engine, out.Values())
+		}
+
+		m = New(program)
+		m.SetEngine(engine)
+		if err := m.Run(); !errors.Is(err, ErrUnknownOpcode) {
+			t.Errorf("%v: standard set ran opcode 10: %v", engine, err)
+		}
+	}
+}
+
+func TestSelfModifyingCustomInstruction(t *testing.T) {
+	set := Standard.Clone()
+	err := set.Register(20, Instruction{Name: "add3", Roles: []Role{Read, Read, Read, Write}, Exec: func(args *Args) error {
+		return args.Set(3, args.Get(0)+args.Get(1)+args.Get(2))
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first add3 writes 3 over its own destination, four cells in, so
+	// the second writes to 3 instead, over its third operand.
+	program := []int{
+		11120, 0, 1, 2, 4, // 0: [4] = 0 + 1 + 2
+		1001, 20, 1, 20, // 5: count the runs
+		1008, 20, 2, 21, // 9
+		1006, 21, 0, // 13: run it again the first time
+		4, 3, // 16
+		99, 0, 0, 0,
+	}
+	for _, engine := range Engines {
+		m := New(program)
+		m.SetEngine(engine)
+		m.SetInstructionSet(set)
+		out := &Queue{}
+		m.Output = out
+		if err := m.Run(); err != nil {
+			t.Fatalf("%v: %v", engine, err)
+		}
+		if fmt.Sprint(out.Values()) != "[3]" {
+			t.Errorf("%v: output = %v, want [3]", engine, out.Values())
+		}
+	}
+}
+
+func TestWriteMode(t *testing.T) {
+	program := []int{11101, 2, 3, 5, 99, 0}
+	for _, engine := range Engines {
+		m := New(program)
+		m.SetEngine(engine)
+		err := m.Run()
+		var mErr *Error
+		if !errors.As(err, &mErr) || !errors.Is(err, ErrImmediateWrite) || mErr.Instruction != 11101 {
+			t.Errorf("%v: strict run returned %v, want an immediate write error at instruction 11101", engine, err)
+		}
+		if m.Memory()[5] != 0 {
+			t.Errorf("%v: strict run wrote %d", engine, m.Memory()[5])
+		}
+
+		m.WriteMode = LenientWrites
+		if err := m.Run(); err != nil {
+			t.Fatalf("%v: lenient run: %v", engine, err)
+		}
+		if m.Memory()[5] != 5 {
+			t.Errorf("%v: lenient run left %d at 5, want 5", engine, m.Memory()[5])
+		}
+	}
+}
+
+func TestObserver(t *testing.T) {
+	var logs []string
+	for _, engine := range Engines {
+		var log []string
+		hooks := &Hooks{
+			Before: func(m *Machine) { log = append(log, fmt.Sprintf("step %d", m.Ip())) },
+			Read:   func(m *Machine, addr, val int) { log = append(log, fmt.Sprintf("read [%d]=%d", addr, val)) },
+			Write:  func(m *Machine, addr, val int) { log = append(log, fmt.Sprintf("write [%d]=%d", addr, val)) },
+			Wait:   func(m *Machine) { log = append(log, "wait") },
+			Halt:   func(m *Machine) { log = append(log, "halt") },
+		}
+		m := New(echoPlusOne)
+		m.SetEngine(engine)
+		m.Observe(hooks)
+		in := NewQueue(41)
+		m.Input = in
+		if err := m.Run(); err != nil {
+			t.Fatal(err)
+		}
+		in.Push(0)
+		if err := m.Run(); err != nil {
+			t.Fatal(err)
+		}
+		m.Unobserve(hooks)
+		logs = append(logs, fmt.Sprint(log))
+	}
+
+	want := "[step 0 write [15]=41 step 2 read [15]=41 step 5 read [15]=41 write [15]=42 " +
+		"step 9 read [15]=42 step 11 step 0 wait step 0 write [15]=0 step 2 read [15]=0 step 14 halt]"
+	for i, engine := range Engines {
+		if logs[i] != want {
+			t.Errorf("%v: observed %s, want %s", engine, logs[i], want)
+		}
+	}
+}
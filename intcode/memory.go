@@ -0,0 +1,185 @@
+package intcode
+
+import "sync"
+
+// Memory is split into pages so that machines cloned from one another can
+// share the pages neither has written to, copying a page only when one of
+// them first writes to it.
+const (
+	pageBits = 8
+	pageSize = 1 << pageBits
+	pageMask = pageSize - 1
+)
+
+type page [pageSize]int
+
+type memory struct {
+	// pages holds nil for a page that has never been written to, which
+	// reads as zeros.
+	pages []*page
+	// shared marks the pages another memory may also be using, which must be
+	// copied before they are written to.
+	shared []bool
+	size   int
+}
+
+func newMemory(program []int) memory {
+	n := (len(program) + pageSize - 1) >> pageBits
+	mem := memory{pages: make([]*page, n), shared: make([]bool, n), size: len(program)}
+	for p := range mem.pages {
+		pg := &page{}
+		copy(pg[:], program[p<<pageBits:])
+		mem.pages[p] = pg
+	}
+	return mem
+}
+
+// get returns the value at addr, which must not be negative.
+func (mem *memory) get(addr int) int {
+	if addr >= mem.size {
+		return 0
+	}
+	if pg := mem.pages[addr>>pageBits]; pg != nil {
+		return pg[addr&pageMask]
+	}
+	return 0
+}
+
+// set stores val at addr, which must not be negative, growing memory to
+// reach it.
+func (mem *memory) set(addr int, val int) {
+	if addr >= mem.size {
+		mem.size = addr + 1
+		if n := addr>>pageBits + 1; n > len(mem.pages) {
+			mem.pages = append(mem.pages, make([]*page, n-len(mem.pages))...)
+			mem.shared = append(mem.shared, make([]bool, n-len(mem.shared))...)
+		}
+	}
+	p := addr >> pageBits
+	pg := mem.pages[p]
+	if pg == nil {
+		pg = &page{}
+		mem.pages[p] = pg
+	} else if mem.shared[p] {
+		copied := *pg
+		pg = &copied
+		mem.pages[p], mem.shared[p] = pg, false
+	}
+	pg[addr&pageMask] = val
+}
+
+// share returns a memory using the same pages as mem, after which both copy a
+// page before writing to it.
+func (mem *memory) share() memory {
+	for p := range mem.shared {
+		mem.shared[p] = true
+	}
+	return memory{
+		pages:  append([]*page(nil), mem.pages...),
+		shared: append([]bool(nil), mem.shared...),
+		size:   mem.size,
+	}
+}
+
+// slice returns a copy of mem as a single slice.
+func (mem *memory) slice() []int {
+	out := make([]int, mem.size)
+	for p, pg := range mem.pages {
+		if pg != nil {
+			copy(out[p<<pageBits:], pg[:])
+		}
+	}
+	return out
+}
+
+// snapshot is the state a machine returns to when it is reset.
+type snapshot struct {
+	mem    memory
+	ip, bp int
+	steps  int
+	status Status
+}
+
+func (m *Machine) snapshot() snapshot {
+	return snapshot{m.mem.share(), m.ip, m.bp, m.steps, m.status}
+}
+
+func (m *Machine) restore(s snapshot) {
+	m.mem = s.mem.share()
+	m.ip, m.bp, m.steps, m.status = s.ip, s.bp, s.steps, s.status
+}
+
+// Clone returns a copy of m in its current state, which shares m's memory
+// until either of them writes to it. The copy has the same engine,
+// instruction set, write mode and memory limit, but no devices or observers,
+// and its Input and Output are left for the caller to set. Resetting the copy
+// returns it to the state it was cloned in.
+func (m *Machine) Clone() *Machine {
+	c := &Machine{
+		engine:      m.engine,
+		set:         m.set,
+		MemoryLimit: m.MemoryLimit,
+		WriteMode:   m.WriteMode,
+	}
+	c.origin = m.snapshot()
+	c.restore(c.origin)
+	return c
+}
+
+// Reset returns m to the state it was created or cloned in. Only the pages of
+// memory it has written to since are replaced, and only the instructions
+// compiled from them are thrown away.
+func (m *Machine) Reset() {
+	if m.code != nil {
+		longest := m.longestInstruction()
+		for p, pg := range m.mem.pages {
+			if p < len(m.origin.mem.pages) && pg == m.origin.mem.pages[p] {
+				continue
+			}
+			// An instruction starting up to its parameter count before the
+			// page may have operands on it.
+			for a := p<<pageBits - longest; a < (p+1)<<pageBits && a < len(m.code); a++ {
+				if a >= 0 {
+					m.code[a] = nil
+				}
+			}
+		}
+		if len(m.code) > m.origin.mem.size {
+			m.code = m.code[:m.origin.mem.size]
+		}
+	}
+	m.restore(m.origin)
+}
+
+// Pool hands out machines that all start in the same state, reusing the ones
+// that are put back rather than building new ones. It is safe for concurrent
+// use.
+type Pool struct {
+	mu   sync.Mutex
+	base *Machine
+	pool sync.Pool
+}
+
+// NewPool returns a pool of machines in the state template is in now.
+func NewPool(template *Machine) *Pool {
+	p := &Pool{base: template.Clone()}
+	p.pool.New = func() interface{} {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return p.base.Clone()
+	}
+	return p
+}
+
+// Get returns a machine from the pool, ready to run.
+func (p *Pool) Get() *Machine {
+	return p.pool.Get().(*Machine)
+}
+
+// Put resets m and returns it to the pool. m must have come from the pool and
+// must not be used afterwards.
+func (p *Pool) Put(m *Machine) {
+	m.Reset()
+	m.Input, m.Output = nil, nil
+	p.pool.Put(m)
+}
@@ -0,0 +1,64 @@
+package intcode
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCloneAndReset(t *testing.T) {
+	// Write past the first page, patch the add at 0 into a multiply and run
+	// it again.
+	program := []int{1101, 2, 3, 1000, 1101, 1102, 0, 0, 1008, 1000, 6, 100, 1006, 100, 0, 99}
+	for _, engine := range Engines {
+		m := New(program)
+		m.SetEngine(engine)
+		if err := m.Step(); err != nil {
+			t.Fatal(err)
+		}
+
+		c := m.Clone()
+		for _, r := range []*Machine{m, c} {
+			if err := r.Run(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := fmt.Sprint(m.Memory()[0], m.Memory()[1000]); got != "1102 6" {
+			t.Errorf("%v: memory = %s", engine, got)
+		}
+
+		// The clone returns to where it was cloned, the original to the
+		// start, and neither sees the other's writes.
+		c.Reset()
+		if c.Ip() != 4 || c.Steps() != 1 || c.Memory()[0] != 1101 || c.Memory()[1000] != 5 {
+			t.Errorf("%v: clone reset to ip %d, steps %d, memory %v", engine, c.Ip(), c.Steps(), c.Memory()[:4])
+		}
+		m.Reset()
+		if m.Ip() != 0 || m.Steps() != 0 || fmt.Sprint(m.Memory()) != fmt.Sprint(program) {
+			t.Errorf("%v: reset to ip %d, steps %d, memory %v", engine, m.Ip(), m.Steps(), m.Memory())
+		}
+		if err := m.Step(); err != nil || m.Memory()[1000] != 5 {
+			t.Errorf("%v: first step after reset: %v, wrote %d", engine, err, m.Memory()[1000])
+		}
+	}
+}
+
+func TestPool(t *testing.T) {
+	template := New([]int{3, 9, 1002, 9, 3, 9, 4, 9, 99, 0})
+	template.SetEngine(Compiled)
+	pool := NewPool(template)
+	for i := 1; i <= 3; i++ {
+		m := pool.Get()
+		out := &Queue{}
+		m.Input, m.Output = NewQueue(i), out
+		if err := m.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(out.Values()) != fmt.Sprint([]int{3 * i}) {
+			t.Errorf("input %d: output = %v", i, out.Values())
+		}
+		pool.Put(m)
+	}
+	if template.Memory()[9] != 0 {
+		t.Errorf("pooled machines wrote %d to the template", template.Memory()[9])
+	}
+}
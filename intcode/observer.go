@@ -0,0 +1,110 @@
+package intcode
+
+// Observer is told what a machine does as it runs, so that tracers,
+// profilers and assertions can be layered on it. Methods are called while
+// the machine is mid-step, so they must not step it themselves.
+type Observer interface {
+	// BeforeStep is called before each instruction is executed, with the
+	// machine on it. An instruction left waiting for input is executed
+	// again, and reported again, each time the machine is run.
+	BeforeStep(m *Machine)
+	// AfterStep is called once the instruction at ip has executed, with the
+	// fault it raised, if any.
+	AfterStep(m *Machine, ip int, err error)
+	// OnRead and OnWrite are called for each value an instruction reads or
+	// writes through a position or relative mode parameter.
+	OnRead(m *Machine, addr int, val int)
+	OnWrite(m *Machine, addr int, val int)
+	// OnWait is called when the machine stops to wait for input.
+	OnWait(m *Machine)
+	// OnHalt is called when the machine halts.
+	OnHalt(m *Machine)
+}
+
+// Hooks is an Observer made of whichever of its functions are set.
+type Hooks struct {
+	Before func(m *Machine)
+	After  func(m *Machine, ip int, err error)
+	Read   func(m *Machine, addr int, val int)
+	Write  func(m *Machine, addr int, val int)
+	Wait   func(m *Machine)
+	Halt   func(m *Machine)
+}
+
+func (h *Hooks) BeforeStep(m *Machine) {
+	if h.Before != nil {
+		h.Before(m)
+	}
+}
+
+func (h *Hooks) AfterStep(m *Machine, ip int, err error) {
+	if h.After != nil {
+		h.After(m, ip, err)
+	}
+}
+
+func (h *Hooks) OnRead(m *Machine, addr int, val int) {
+	if h.Read != nil {
+		h.Read(m, addr, val)
+	}
+}
+
+func (h *Hooks) OnWrite(m *Machine, addr int, val int) {
+	if h.Write != nil {
+		h.Write(m, addr, val)
+	}
+}
+
+func (h *Hooks) OnWait(m *Machine) {
+	if h.Wait != nil {
+		h.Wait(m)
+	}
+}
+
+func (h *Hooks) OnHalt(m *Machine) {
+	if h.Halt != nil {
+		h.Halt(m)
+	}
+}
+
+// Observe adds o to the observers of m, which are called in the order they
+// were added.
+func (m *Machine) Observe(o Observer) {
+	m.observers = append(m.observers, o)
+	// Compiled instructions only check for observers when they are compiled.
+	m.code = nil
+}
+
+// Unobserve removes o from the observers of m.
+func (m *Machine) Unobserve(o Observer) {
+	for i, obs := range m.observers {
+		if obs == o {
+			m.observers = append(m.observers[:i:i], m.observers[i+1:]...)
+			m.code = nil
+			return
+		}
+	}
+}
+
+// load reads a value for an instruction, telling the observers.
+func (m *Machine) load(addr int) (int, error) {
+	val, err := m.read(addr)
+	if err != nil {
+		return val, err
+	}
+	for _, o := range m.observers {
+		o.OnRead(m, addr, val)
+	}
+	return val, nil
+}
+
+// store writes a value for an instruction, telling the observers.
+func (m *Machine) store(addr int, val int) error {
+	if err := m.write(addr, val); err != nil {
+		return err
+	}
+	for _, o := range m.observers {
+		o.OnWrite(m, addr, val)
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package intcode
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Rewrite is a change the optimizer made to a program. Before and After are
+// the cells starting at Addr.
+type Rewrite struct {
+	Addr   int
+	Pass   string
+	Before []int
+	After  []int
+}
+
+func (r Rewrite) String() string {
+	return fmt.Sprintf("%s at %d: %v -> %v", r.Pass, r.Addr, r.Before, r.After)
+}
+
+// Optimize returns a rewritten copy of program along with the changes made.
+// It only touches instructions that can be reached from address 0 and whose
+// cells the program never writes or reads as data. Programs that modify their
+// own code or jump to computed addresses are left alone, as there is no
+// telling what code they run, and so are the constants and jumps of programs
+// that write through relative mode parameters, as those writes could land
+// anywhere.
+// Use Verify to check that holds for a given input.
+//
+// The passes are:
+//
+//	fold    arithmetic and comparisons on constants become an add of the
+//	        result and 0
+//	thread  jumps to an unconditional jump go straight to its target
+//	trim    cells past the last reachable instruction or referenced address
+//	        are dropped, if no relative mode parameter could address them
+//
+// Unreachable cells between reachable instructions are left as they are, as
+// removing them would move the code after them.
+func Optimize(program []int) ([]int, []Rewrite) {
+	optimized := append([]int(nil), program...)
+	var rewrites []Rewrite
+	rewrite := func(addr int, pass string, cells ...int) {
+		before := append([]int(nil), optimized[addr:addr+len(cells)]...)
+		if fmt.Sprint(before) == fmt.Sprint(cells) {
+			return
+		}
+		copy(optimized[addr:], cells)
+		rewrites = append(rewrites, Rewrite{addr, pass, before, cells})
+	}
+
+	f := analyseFlow(optimized, 0)
+	if !f.complete() {
+		return optimized, nil
+	}
+	for _, addr := range f.addresses() {
+		if f.stable(addr) {
+			foldConstants(f, f.instrs[addr], rewrite)
+		}
+	}
+	for _, addr := range f.addresses() {
+		if f.stable(addr) {
+			threadJump(f, f.instrs[addr], rewrite)
+		}
+	}
+
+	// Threading can leave jumps unreachable, so look again before trimming.
+	f = analyseFlow(optimized, 0)
+	if end := f.end(); end < len(optimized) {
+		rewrites = append(rewrites, Rewrite{end, "trim", optimized[end:], nil})
+		optimized = optimized[:end]
+	}
+	return optimized, rewrites
+}
+
+func foldConstants(f *flow, in *staticInstr, rewrite func(int, string, ...int)) {
+	op := in.op
+	if op.opcode != 1 && op.opcode != 2 && op.opcode != 7 && op.opcode != 8 {
+		return
+	}
+	a, okA := f.value(op.params[0])
+	b, okB := f.value(op.params[1])
+	dest := op.params[2]
+	if !okA || !okB || dest.mode == immediateMode {
+		return
+	}
+	var result int
+	switch op.opcode {
+	case 1:
+		result = a + b
+	case 2:
+		result = a * b
+	case 7:
+		result = boolToInt(a < b)
+	case 8:
+		result = boolToInt(a == b)
+	}
+	rewrite(in.addr, "fold", 1101+10000*dest.mode, result, 0, dest.val)
+}
+
+// unconditionalTarget returns where the jump at addr always goes.
+func unconditionalTarget(f *flow, addr int) (int, bool) {
+	in, ok := f.instrs[addr]
+	if !ok || (in.op.opcode != 5 && in.op.opcode != 6) || !f.stable(addr) {
+		return 0, false
+	}
+	cond, ok := f.value(in.op.params[0])
+	if !ok || (cond != 0) != (in.op.opcode == 5) {
+		return 0, false
+	}
+	return f.value(in.op.params[1])
+}
+
+func threadJump(f *flow, in *staticInstr, rewrite func(int, string, ...int)) {
+	op := in.op
+	if op.opcode != 5 && op.opcode != 6 {
+		return
+	}
+	target, ok := f.value(op.params[1])
+	if !ok {
+		return
+	}
+	seen := map[int]bool{in.addr: true}
+	final := target
+	for !seen[final] {
+		seen[final] = true
+		next, ok := unconditionalTarget(f, final)
+		if !ok {
+			break
+		}
+		final = next
+	}
+	if final == target {
+		return
+	}
+	// Keep the condition's mode and make the target immediate.
+	rewrite(in.addr, "thread", op.opcode+100*op.params[0].mode+1000*immediateMode, op.params[0].val, final)
+}
+
+// end returns the length the program can be cut to without any reachable
+// instruction noticing, or the full length if that cannot be known.
+func (f *flow) end() int {
+	if !f.complete() || f.relative {
+		return len(f.program)
+	}
+	end := 0
+	extend := func(addr int) {
+		if addr+1 > end {
+			end = addr + 1
+		}
+	}
+	for addr, in := range f.instrs {
+		extend(addr + in.size() - 1)
+	}
+	for addr := range f.read {
+		extend(addr)
+	}
+	for addr := range f.written {
+		extend(addr)
+	}
+	for _, addr := range f.faults {
+		extend(addr)
+	}
+	if end > len(f.program) {
+		return len(f.program)
+	}
+	return end
+}
+
+var (
+	ErrOptimizedDiffers = errors.New("optimized program behaves differently")
+	// ErrInconclusive is returned by Verify when it gives up before either
+	// program stopped, or both need more input than it was given, so nothing
+	// past the outputs compared so far is known.
+	ErrInconclusive = errors.New("verification inconclusive")
+)
+
+// Verify runs original and optimized side by side with the same inputs,
+// comparing each output as it is produced, how the two stop and, once they
+// halt, the memory cells none of rewrites touched. It gives up with
+// ErrInconclusive once either has run maxSteps instructions, or if both wait
+// for more input than inputs holds.
+func Verify(original, optimized []int, rewrites []Rewrite, inputs []int, maxSteps int) error {
+	type side struct {
+		m   *Machine
+		out *Queue
+		err error
+	}
+	sides := [2]*side{}
+	for i, program := range [][]int{original, optimized} {
+		s := &side{m: New(program), out: &Queue{}}
+		s.m.Input, s.m.Output = NewQueue(inputs...), s.out
+		sides[i] = s
+	}
+
+	// advance runs s until it has produced n outputs or stopped.
+	advance := func(s *side, n int) bool {
+		for s.err == nil && s.m.Status() == Running && s.out.Len() < n {
+			if s.m.Steps() >= maxSteps {
+				return false
+			}
+			s.err = s.m.Step()
+		}
+		return true
+	}
+
+	for n := 1; ; n++ {
+		if !advance(sides[0], n) || !advance(sides[1], n) {
+			return fmt.Errorf("%w: stopped after %d instructions and %d matching outputs", ErrInconclusive, maxSteps, n-1)
+		}
+		a, b := sides[0], sides[1]
+		if a.out.Len() != b.out.Len() {
+			return fmt.Errorf("%w: %d outputs from the original, %d from the optimized program", ErrOptimizedDiffers, a.out.Len(), b.out.Len())
+		}
+		if a.out.Len() == n {
+			if av, bv := a.out.Values()[n-1], b.out.Values()[n-1]; av != bv {
+				return fmt.Errorf("%w: output %d is %d, optimized gives %d", ErrOptimizedDiffers, n-1, av, bv)
+			}
+			continue
+		}
+
+		if (a.err == nil) != (b.err == nil) || a.m.Status() != b.m.Status() {
+			return fmt.Errorf("%w: original stopped with %v (%v), optimized with %v (%v)",
+				ErrOptimizedDiffers, a.m.Status(), a.err, b.m.Status(), b.err)
+		}
+		if a.m.Status() == Halted {
+			return verifyMemory(a.m.Memory(), b.m.Memory(), rewrites)
+		}
+		return fmt.Errorf("%w: both programs need more than %d inputs after %d matching outputs", ErrInconclusive, len(inputs), n-1)
+	}
+}
+
+func verifyMemory(original, optimized []int, rewrites []Rewrite) error {
+	touched := map[int]bool{}
+	for _, r := range rewrites {
+		for a := r.Addr; a < r.Addr+len(r.Before); a++ {
+			touched[a] = true
+		}
+	}
+	cell := func(memory []int, addr int) int {
+		if addr < len(memory) {
+			return memory[addr]
+		}
+		return 0
+	}
+	for addr := 0; addr < len(original) || addr < len(optimized); addr++ {
+		if touched[addr] {
+			continue
+		}
+		if a, b := cell(original, addr), cell(optimized, addr); a != b {
+			return fmt.Errorf("%w: memory at %d is %d, optimized leaves %d", ErrOptimizedDiffers, addr, a, b)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package intcode
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	program := []int{
+		1101, 2, 3, 17, // 0: fold to 1101,5,0,17
+		1105, 1, 9, // 4: jump to a jump, threaded to 12
+		99, 99, // 7
+		1106, 0, 12, // 9: unconditional jump to 12
+		4, 17, // 12: output cell 17
+		99,   // 14
+		7, 7, // 15: unreachable, but only the tail is trimmed
+		0,    // 17
+		0, 0, // 18: unreachable, trimmed
+	}
+	optimized, rewrites := Optimize(program)
+
+	want := []string{
+		"fold at 0: [1101 2 3 17] -> [1101 5 0 17]",
+		"thread at 4: [1105 1 9] -> [1105 1 12]",
+		"trim at 18: [0 0] -> []",
+	}
+	if fmt.Sprint(rewrites) != fmt.Sprint(want) {
+		t.Errorf("rewrites = %v, want %v", rewrites, want)
+	}
+	if err := Verify(program, optimized, rewrites, nil, 1000); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOptimizeRelativeWrites(t *testing.T) {
+	// The input lands in cell 50 through bp, so the add reading it must not
+	// be folded as if 50 still held 0.
+	program := []int{109, 50, 203, 0, 1, 50, 50, 60, 4, 60, 99}
+	optimized, rewrites := Optimize(program)
+	if len(rewrites) != 0 {
+		t.Errorf("rewrites = %v, want none", rewrites)
+	}
+	if err := Verify(program, optimized, rewrites, []int{21}, 1000); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestVerifyInconclusive(t *testing.T) {
+	loop := []int{1105, 1, 0}
+	if err := Verify(loop, loop, nil, nil, 1000); !errors.Is(err, ErrInconclusive) {
+		t.Errorf("err = %v, want %v", err, ErrInconclusive)
+	}
+
+	// Too few inputs to reach the halt says nothing about the memory.
+	echo := []int{3, 0, 4, 0, 3, 0, 99}
+	if err := Verify(echo, echo, nil, []int{1}, 1000); !errors.Is(err, ErrInconclusive) {
+		t.Errorf("err with too few inputs = %v, want %v", err, ErrInconclusive)
+	}
+}
+
+func TestOptimizeCorpus(t *testing.T) {
+	cases, err := Corpus("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		optimized, rewrites := Optimize(c.Program)
+		if err := Verify(c.Program, optimized, rewrites, c.Inputs, 1000000); err != nil {
+			t.Errorf("%s: %v\nrewrites: %v", c.Name, err, rewrites)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package intcode
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Role says how an instruction uses one of its parameters.
+type Role int
+
+const (
+	// Read parameters are loaded according to their mode before the
+	// instruction runs.
+	Read Role = iota
+	// Write parameters name the address the instruction stores its result in.
+	Write
+)
+
+// Handler carries out an instruction. Unless it calls Jump, Halt or Wait the
+// machine moves on to the instruction that follows.
+type Handler func(args *Args) error
+
+// Instruction describes an opcode: one Role per parameter and the Handler
+// that executes it.
+type Instruction struct {
+	Name  string
+	Roles []Role
+	Exec  Handler
+
+	// standard is set on the instructions of Standard, which the compiled
+	// engine knows how to specialise.
+	standard bool
+}
+
+// Args is an executing instruction's view of its parameters and machine.
+type Args struct {
+	m      *Machine
+	params []Parameter
+	vals   []int
+	next   int
+}
+
+func (a *Args) Machine() *Machine {
+	return a.m
+}
+
+// Get returns the value of read parameter i.
+func (a *Args) Get(i int) int {
+	return a.vals[i]
+}
+
+// Set stores val through write parameter i.
+func (a *Args) Set(i int, val int) error {
+	return a.m.setVal(a.params[i], val)
+}
+
+// Jump continues execution from ip instead of the next instruction.
+func (a *Args) Jump(ip int) {
+	a.next = ip
+}
+
+// Halt stops the machine on this instruction.
+func (a *Args) Halt() {
+	a.m.status = Halted
+}
+
+// Wait leaves the machine on this instruction until it is run again, e.g.
+// because the input it needs is not yet available.
+func (a *Args) Wait() {
+	a.m.status = WaitingInput
+}
+
+var ErrOpcodeTaken = errors.New("opcode already registered")
+
+// InstructionSet maps opcodes to the instructions they execute.
+type InstructionSet struct {
+	instructions map[int]*Instruction
+	// longest is the most parameters any instruction registered has, which
+	// bounds how far back a write can land inside an instruction.
+	longest int
+}
+
+func NewInstructionSet() *InstructionSet {
+	return &InstructionSet{instructions: map[int]*Instruction{}}
+}
+
+// Register adds an instruction under opcode, which must be between 1 and 99
+// so that it fits below the parameter modes.
+func (s *InstructionSet) Register(opcode int, in Instruction) error {
+	if opcode < 1 || opcode > 99 {
+		return fmt.Errorf("%w: %d does not fit in two digits", ErrUnknownOpcode, opcode)
+	}
+	if _, ok := s.instructions[opcode]; ok {
+		return fmt.Errorf("%w: %d", ErrOpcodeTaken, opcode)
+	}
+	if in.Exec == nil {
+		return fmt.Errorf("opcode %d has no handler", opcode)
+	}
+	in.standard = false
+	s.instructions[opcode] = &in
+	if len(in.Roles) > s.longest {
+		s.longest = len(in.Roles)
+	}
+	return nil
+}
+
+// Replace registers in under opcode whether or not it is already taken.
+func (s *InstructionSet) Replace(opcode int, in Instruction) error {
+	delete(s.instructions, opcode)
+	return s.Register(opcode, in)
+}
+
+func (s *InstructionSet) Lookup(opcode int) (Instruction, bool) {
+	in, ok := s.instructions[opcode]
+	if !ok {
+		return Instruction{}, false
+	}
+	return *in, true
+}
+
+// Opcodes lists the registered opcodes in order.
+func (s *InstructionSet) Opcodes() []int {
+	var opcodes []int
+	for opcode := range s.instructions {
+		opcodes = append(opcodes, opcode)
+	}
+	sort.Ints(opcodes)
+	return opcodes
+}
+
+// Clone returns a copy of s that can be extended without changing s.
+func (s *InstructionSet) Clone() *InstructionSet {
+	c := NewInstructionSet()
+	for opcode, in := range s.instructions {
+		c.instructions[opcode] = in
+	}
+	c.longest = s.longest
+	return c
+}
+
+// standardLongest is the most parameters a Standard instruction has.
+const standardLongest = 3
+
+// Standard is the instruction set as of day 9.
+var Standard = func() *InstructionSet {
+	s := NewInstructionSet()
+	add := func(opcode int, name string, roles []Role, exec Handler) {
+		s.instructions[opcode] = &Instruction{name, roles, exec, true}
+		if len(roles) > s.longest {
+			s.longest = len(roles)
+		}
+	}
+	rrw := []Role{Read, Read, Write}
+
+	add(1, "add", rrw, func(args *Args) error {
+		return args.Set(2, args.Get(0)+args.Get(1))
+	})
+	add(2, "mul", rrw, func(args *Args) error {
+		return args.Set(2, args.Get(0)*args.Get(1))
+	})
+	add(3, "in", []Role{Write}, func(args *Args) error {
+		m := args.m
+		if m.Input == nil {
+			args.Wait()
+			return nil
+		}
+		input, err := m.Input.Read()
+		if errors.Is(err, ErrNoInput) {
+			args.Wait()
+			return nil
+		} else if err != nil {
+			return err
+		}
+		return args.Set(0, input)
+	})
+	add(4, "out", []Role{Read}, func(args *Args) error {
+		if args.m.Output == nil {
+			return nil
+		}
+		return args.m.Output.Write(args.Get(0))
+	})
+	add(5, "jnz", []Role{Read, Read}, func(args *Args) error {
+		if args.Get(0) != 0 {
+			args.Jump(args.Get(1))
+		}
+		return nil
+	})
+	add(6, "jz", []Role{Read, Read}, func(args *Args) error {
+		if args.Get(0) == 0 {
+			args.Jump(args.Get(1))
+		}
+		return nil
+	})
+	add(7, "lt", rrw, func(args *Args) error {
+		return args.Set(2, boolToInt(args.Get(0) < args.Get(1)))
+	})
+	add(8, "eq", rrw, func(args *Args) error {
+		return args.Set(2, boolToInt(args.Get(0) == args.Get(1)))
+	})
+	add(9, "arb", []Role{Read}, func(args *Args) error {
+		args.m.bp += args.Get(0)
+		return nil
+	})
+	add(99, "halt", nil, func(args *Args) error {
+		args.Halt()
+		return nil
+	})
+	return s
+}()
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// decode looks up the instruction packed into an instruction value and
+// decodes its parameter modes. The parameter values are left for the caller
+// to fill in from the cells that follow the instruction.
+func (s *InstructionSet) decode(instruction int) (op Operation, err error) {
+	if instruction < 0 {
+		return op, fmt.Errorf("%w: %d", ErrUnknownOpcode, instruction)
+	}
+
+	op.opcode = instruction % 100
+	in, ok := s.instructions[op.opcode]
+	if !ok {
+		return op, fmt.Errorf("%w: %d", ErrUnknownOpcode, op.opcode)
+	}
+	op.instruction = in
+	op.nParams = len(in.Roles)
+
+	modes := instruction / 100
+	for p := 0; p < op.nParams; p, modes = p+1, modes/10 {
+		mode := modes % 10
+		if mode != positionMode && mode != immediateMode && mode != relativeMode {
+			return op, fmt.Errorf("%w: %d in instruction %d", ErrUnknownMode, mode, instruction)
+		}
+		op.params = append(op.params, Parameter{mode: mode})
+	}
+	return op, nil
+}
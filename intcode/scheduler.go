@@ -0,0 +1,204 @@
+package intcode
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultQuantum is how many instructions a scheduled machine executes each
+// round unless the scheduler's Quantum says otherwise.
+const DefaultQuantum = 1000
+
+// ErrDeadlock is returned when no scheduled machine can make progress.
+var ErrDeadlock = errors.New("deadlock")
+
+// MachineState is where a scheduled machine had got to when the scheduler
+// stopped. Pending is how many values were waiting in its input, if the input
+// can tell.
+type MachineState struct {
+	ID      int
+	Ip      int
+	Status  Status
+	Pending int
+}
+
+// DeadlockError is returned when no scheduled machine can make progress:
+// every one that has not halted is waiting for input that has not arrived.
+type DeadlockError struct {
+	Machines []MachineState
+}
+
+// Blocked returns the IDs of the machines waiting for input.
+func (e *DeadlockError) Blocked() []int {
+	var ids []int
+	for _, m := range e.Machines {
+		if m.Status == WaitingInput {
+			ids = append(ids, m.ID)
+		}
+	}
+	return ids
+}
+
+func (e *DeadlockError) Error() string {
+	var states []string
+	for _, m := range e.Machines {
+		state := fmt.Sprintf("%d ip=%d %s", m.ID, m.Ip, m.Status)
+		if m.Pending > 0 {
+			state += fmt.Sprintf(" (%d pending)", m.Pending)
+		}
+		states = append(states, state)
+	}
+	return fmt.Sprintf("%v, no machine can make progress: %s", ErrDeadlock, strings.Join(states, ", "))
+}
+
+func (e *DeadlockError) Unwrap() error {
+	return ErrDeadlock
+}
+
+// SchedulerError is a fault raised by one of the scheduled machines.
+type SchedulerError struct {
+	ID  int
+	Err error
+}
+
+func (e *SchedulerError) Error() string {
+	return fmt.Sprintf("machine %d: %v", e.ID, e.Err)
+}
+
+func (e *SchedulerError) Unwrap() error {
+	return e.Err
+}
+
+// pending is implemented by inputs that can say whether a read would block,
+// such as Queue, so that machines waiting on them can be skipped.
+type pending interface {
+	Len() int
+}
+
+// Scheduler runs many machines on the calling goroutine, taking turns in the
+// order they were added. Each round every machine that is not halted or
+// blocked executes up to Quantum instructions, giving up the rest of its turn
+// as soon as it waits for input. Machines are never run concurrently and
+// always in the same order, so a simulation built from them is reproducible.
+type Scheduler struct {
+	// Quantum is how many instructions each machine executes per turn. Zero
+	// means DefaultQuantum.
+	Quantum int
+
+	machines []*Machine
+	rounds   int
+}
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{}
+}
+
+// Add schedules m and returns its ID, the order it takes its turn in.
+func (s *Scheduler) Add(m *Machine) int {
+	s.machines = append(s.machines, m)
+	return len(s.machines) - 1
+}
+
+// Machine returns the machine with the given ID.
+func (s *Scheduler) Machine(id int) *Machine {
+	return s.machines[id]
+}
+
+// Len returns how many machines are scheduled.
+func (s *Scheduler) Len() int {
+	return len(s.machines)
+}
+
+// Rounds returns how many rounds have been run.
+func (s *Scheduler) Rounds() int {
+	return s.rounds
+}
+
+// Connect sends the output of machine from to the input of machine to and
+// returns the queue between them. If to already reads from a Queue the output
+// is appended to it, so values pushed there first are read first.
+func (s *Scheduler) Connect(from, to int) *Queue {
+	q, ok := s.machines[to].Input.(*Queue)
+	if !ok {
+		q = &Queue{}
+		s.machines[to].Input = q
+	}
+	s.machines[from].Output = q
+	return q
+}
+
+// blocked reports whether m cannot execute anything until its input changes.
+func blocked(m *Machine) bool {
+	if m.status != WaitingInput {
+		return false
+	}
+	p, ok := m.Input.(pending)
+	return ok && p.Len() == 0
+}
+
+// Round gives each machine one turn and reports whether any of them executed
+// an instruction.
+func (s *Scheduler) Round() (bool, error) {
+	quantum := s.Quantum
+	if quantum <= 0 {
+		quantum = DefaultQuantum
+	}
+	s.rounds++
+
+	progressed := false
+	for id, m := range s.machines {
+		if m.status == Halted || blocked(m) {
+			continue
+		}
+		before := m.steps
+		for i := 0; i < quantum; i++ {
+			if err := m.Step(); err != nil {
+				return progressed, &SchedulerError{id, err}
+			}
+			if m.status != Running {
+				break
+			}
+		}
+		progressed = progressed || m.steps != before
+	}
+	return progressed, nil
+}
+
+// Run runs rounds until every machine has halted. It returns a
+// *DeadlockError if a round passes with machines left that are all waiting
+// for input, and a *SchedulerError if a machine faults.
+func (s *Scheduler) Run() error {
+	for !s.Halted() {
+		progressed, err := s.Round()
+		if err != nil {
+			return err
+		}
+		if !progressed {
+			return s.deadlock()
+		}
+	}
+	return nil
+}
+
+// Halted reports whether every machine has halted.
+func (s *Scheduler) Halted() bool {
+	for _, m := range s.machines {
+		if m.status != Halted {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Scheduler) deadlock() error {
+	err := &DeadlockError{}
+	for id, m := range s.machines {
+		state := MachineState{ID: id, Ip: m.ip, Status: m.status}
+		if p, ok := m.Input.(pending); ok {
+			state.Pending = p.Len()
+		}
+		err.Machines = append(err.Machines, state)
+	}
+	return err
+}
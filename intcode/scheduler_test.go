@@ -0,0 +1,100 @@
+package intcode
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestSchedulerFeedback(t *testing.T) {
+	program := []int{3, 26, 1001, 26, -4, 26, 3, 27, 1002, 27, 2, 27, 1, 27, 26,
+		27, 4, 27, 1001, 28, -1, 28, 1005, 28, 6, 99, 0, 0, 5}
+	s := NewScheduler()
+	s.Quantum = 3
+	for _, phase := range []int{9, 8, 7, 6, 5} {
+		m := New(program)
+		m.Input = NewQueue(phase)
+		s.Add(m)
+	}
+	for id := 0; id < s.Len(); id++ {
+		s.Connect(id, (id+1)%s.Len())
+	}
+	s.Machine(0).Input.(*Queue).Push(0)
+	if err := s.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Machine(0).Input.(*Queue).Values(); fmt.Sprint(got) != "[139629729]" {
+		t.Errorf("signal = %v, want [139629729]", got)
+	}
+}
+
+func TestSchedulerOrder(t *testing.T) {
+	for _, tc := range []struct {
+		quantum int
+		want    string
+	}{
+		{1, "[1 10 2 20 3 30]"},
+		{2, "[1 2 10 20 3 30]"},
+		{0, "[1 2 3 10 20 30]"},
+	} {
+		s := NewScheduler()
+		s.Quantum = tc.quantum
+		out := &Queue{}
+		for _, program := range [][]int{{104, 1, 104, 2, 104, 3, 99}, {104, 10, 104, 20, 104, 30, 99}} {
+			m := New(program)
+			m.Output = out
+			s.Add(m)
+		}
+		if err := s.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprint(out.Values()); got != tc.want {
+			t.Errorf("quantum %d: output = %s, want %s", tc.quantum, got, tc.want)
+		}
+	}
+}
+
+func TestSchedulerDeadlock(t *testing.T) {
+	// Each machine echoes its input once it has some, so a ring of them with
+	// nothing to start it never gets going, while the halting one does.
+	echo := []int{3, 0, 4, 0, 99}
+	s := NewScheduler()
+	for i := 0; i < 3; i++ {
+		s.Add(New(echo))
+	}
+	s.Add(New([]int{99}))
+	s.Connect(0, 1)
+	s.Connect(1, 2)
+	s.Connect(2, 0)
+
+	err := s.Run()
+	var deadlock *DeadlockError
+	if !errors.As(err, &deadlock) || !errors.Is(err, ErrDeadlock) {
+		t.Fatalf("err = %v, want a deadlock", err)
+	}
+	if fmt.Sprint(deadlock.Blocked()) != "[0 1 2]" || s.Machine(3).Status() != Halted {
+		t.Errorf("blocked = %v, last machine %v", deadlock.Blocked(), s.Machine(3).Status())
+	}
+	if want := (MachineState{ID: 1, Ip: 0, Status: WaitingInput}); deadlock.Machines[1] != want {
+		t.Errorf("machine 1 = %+v, want %+v", deadlock.Machines[1], want)
+	}
+
+	s.Machine(0).Input.(*Queue).Push(7)
+	if err := s.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Machine(0).Input.(*Queue).Values(); fmt.Sprint(got) != "[7]" {
+		t.Errorf("ring delivered %v, want [7]", got)
+	}
+}
+
+func TestSchedulerFault(t *testing.T) {
+	s := NewScheduler()
+	s.Add(New([]int{104, 1, 99}))
+	s.Add(New([]int{42}))
+	err := s.Run()
+	var fault *SchedulerError
+	if !errors.As(err, &fault) || fault.ID != 1 {
+		t.Fatalf("err = %v, want a fault in machine 1", err)
+	}
+}
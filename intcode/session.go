@@ -0,0 +1,292 @@
+package intcode
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A session is stored as text, one event per line:
+//
+//	program 1a2b3c4d
+//	0 in 5
+//	12 out 42
+//	end halted
+//
+// The program line holds the checksum of the program the session was recorded
+// from, each event its step count, direction and value, and the end line is
+// only present if the program halted.
+
+// EventKind says which way a value in a session went.
+type EventKind int
+
+const (
+	InputEvent EventKind = iota
+	OutputEvent
+)
+
+func (k EventKind) String() string {
+	if k == InputEvent {
+		return "in"
+	}
+	return "out"
+}
+
+// Event is a value consumed or produced by the instruction executed after Step
+// others.
+type Event struct {
+	Step  int
+	Kind  EventKind
+	Value int
+}
+
+func (e Event) String() string {
+	if e.Kind == InputEvent {
+		return fmt.Sprintf("input %d at step %d", e.Value, e.Step)
+	}
+	return fmt.Sprintf("output %d at step %d", e.Value, e.Step)
+}
+
+// Session is the I/O of a run, enough to replay it without whoever or
+// whatever supplied its input.
+type Session struct {
+	Checksum uint32
+	Events   []Event
+	Halted   bool
+}
+
+var (
+	ErrBadSession = errors.New("malformed session")
+	// ErrSessionProgram is returned when replaying a session against a
+	// different program from the one it was recorded with.
+	ErrSessionProgram = errors.New("session was recorded with a different program")
+	ErrDiverged       = errors.New("replay diverged from the session")
+)
+
+// Mismatch is the first point at which a replay did something other than
+// what the session recorded.
+type Mismatch struct {
+	// Index is the position in the session of the event that was expected.
+	Index int
+	Want  string
+	Got   string
+}
+
+func (d *Mismatch) Error() string {
+	return fmt.Sprintf("event %d: expected %s, got %s", d.Index, d.Want, d.Got)
+}
+
+func (d *Mismatch) Unwrap() error {
+	return ErrDiverged
+}
+
+// ProgramChecksum returns the CRC-32 a session uses to identify program.
+func ProgramChecksum(program []int) uint32 {
+	h := crc32.NewIEEE()
+	word := make([]byte, binary.MaxVarintLen64)
+	for _, v := range program {
+		n := binary.PutVarint(word, int64(v))
+		h.Write(word[:n])
+	}
+	return h.Sum32()
+}
+
+// Recorder captures a machine's I/O as a Session.
+type Recorder struct {
+	m       *Machine
+	session Session
+}
+
+type recordingInput struct {
+	r  *Recorder
+	in Input
+}
+
+func (i recordingInput) Read() (int, error) {
+	if i.in == nil {
+		return 0, ErrNoInput
+	}
+	val, err := i.in.Read()
+	if err == nil {
+		i.r.session.Events = append(i.r.session.Events, Event{i.r.m.Steps(), InputEvent, val})
+	}
+	return val, err
+}
+
+type recordingOutput struct {
+	r   *Recorder
+	out Output
+}
+
+func (o recordingOutput) Write(val int) error {
+	o.r.session.Events = append(o.r.session.Events, Event{o.r.m.Steps(), OutputEvent, val})
+	if o.out == nil {
+		return nil
+	}
+	return o.out.Write(val)
+}
+
+// Record starts recording m, which must not have run yet, by wrapping its
+// Input and Output. Set those before calling it.
+func Record(m *Machine) *Recorder {
+	r := &Recorder{m: m, session: Session{Checksum: ProgramChecksum(m.Memory())}}
+	m.Input, m.Output = recordingInput{r, m.Input}, recordingOutput{r, m.Output}
+	return r
+}
+
+// Session returns what has been recorded so far.
+func (r *Recorder) Session() *Session {
+	s := r.session
+	s.Events = append([]Event(nil), s.Events...)
+	s.Halted = r.m.Status() == Halted
+	return &s
+}
+
+// WriteSession writes s in the text format.
+func WriteSession(w io.Writer, s *Session) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "program %08x\n", s.Checksum)
+	for _, e := range s.Events {
+		fmt.Fprintf(bw, "%d %v %d\n", e.Step, e.Kind, e.Value)
+	}
+	if s.Halted {
+		fmt.Fprintln(bw, "end halted")
+	}
+	return bw.Flush()
+}
+
+// ReadSession reads a session in the text format. Errors are a *ParseError
+// whose Index is the number of events read before it.
+func ReadSession(r io.Reader) (*Session, error) {
+	s := &Session{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	seenProgram := false
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		fail := func(err error) error {
+			return &ParseError{Line: line, Column: 1, Index: len(s.Events), Text: text, Err: err}
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if s.Halted {
+			return nil, fail(fmt.Errorf("%w: events after the end", ErrBadSession))
+		}
+
+		switch {
+		case len(fields) == 2 && fields[0] == "program" && !seenProgram:
+			sum, err := strconv.ParseUint(fields[1], 16, 32)
+			if err != nil {
+				return nil, fail(fmt.Errorf("%w: %v", ErrBadSession, err))
+			}
+			s.Checksum, seenProgram = uint32(sum), true
+		case !seenProgram:
+			return nil, fail(fmt.Errorf("%w: expected the program checksum first", ErrBadSession))
+		case len(fields) == 2 && fields[0] == "end" && fields[1] == "halted":
+			s.Halted = true
+		case len(fields) == 3 && (fields[1] == "in" || fields[1] == "out"):
+			step, err := strconv.Atoi(fields[0])
+			if err != nil {
+				return nil, fail(fmt.Errorf("%w: step: %v", ErrBadSession, err))
+			}
+			val, err := strconv.Atoi(fields[2])
+			if err != nil {
+				return nil, fail(fmt.Errorf("%w: value: %v", ErrBadSession, err))
+			}
+			if n := len(s.Events); step < 0 || (n > 0 && step < s.Events[n-1].Step) {
+				return nil, fail(fmt.Errorf("%w: step %d is out of order", ErrBadSession, step))
+			}
+			kind := InputEvent
+			if fields[1] == "out" {
+				kind = OutputEvent
+			}
+			s.Events = append(s.Events, Event{step, kind, val})
+		default:
+			return nil, fail(ErrBadSession)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !seenProgram {
+		return nil, fmt.Errorf("%w: no program checksum", ErrBadSession)
+	}
+	return s, nil
+}
+
+// replayer feeds a machine the inputs of a session and checks its outputs
+// against it, one event at a time.
+type replayer struct {
+	m      *Machine
+	events []Event
+	next   int
+}
+
+// expect checks that got is the next event, returning a *Mismatch if not.
+func (r *replayer) expect(got Event) error {
+	if r.next >= len(r.events) {
+		if got.Kind == InputEvent {
+			// The recorded run stopped here waiting for input.
+			return ErrNoInput
+		}
+		return &Mismatch{r.next, "no more events", got.String()}
+	}
+	want := r.events[r.next]
+	if want.Kind != got.Kind || want.Step != got.Step || (got.Kind == OutputEvent && want.Value != got.Value) {
+		if got.Kind == InputEvent {
+			return &Mismatch{r.next, want.String(), fmt.Sprintf("input at step %d", got.Step)}
+		}
+		return &Mismatch{r.next, want.String(), got.String()}
+	}
+	r.next++
+	return nil
+}
+
+func (r *replayer) Read() (int, error) {
+	if err := r.expect(Event{Step: r.m.Steps(), Kind: InputEvent}); err != nil {
+		return 0, err
+	}
+	return r.events[r.next-1].Value, nil
+}
+
+func (r *replayer) Write(val int) error {
+	return r.expect(Event{r.m.Steps(), OutputEvent, val})
+}
+
+// Replay runs m, which must not have run yet, feeding it the inputs recorded
+// in s. It returns a *Mismatch at the first input or output that does not
+// match the session, or if m halts before the session did or vice versa.
+func Replay(m *Machine, s *Session) error {
+	if sum := ProgramChecksum(m.Memory()); sum != s.Checksum {
+		return fmt.Errorf("%w: checksum %08x, session has %08x", ErrSessionProgram, sum, s.Checksum)
+	}
+	r := &replayer{m: m, events: s.Events}
+	m.Input, m.Output = r, r
+
+	var d *Mismatch
+	if err := m.Run(); errors.As(err, &d) {
+		return d
+	} else if err != nil {
+		return err
+	}
+
+	// Run only stops short of halting once the inputs have run out.
+	halted := m.Status() == Halted
+	if r.next < len(r.events) {
+		return &Mismatch{r.next, r.events[r.next].String(), fmt.Sprintf("halt at step %d", m.Steps())}
+	}
+	if halted && !s.Halted {
+		return &Mismatch{r.next, "input", fmt.Sprintf("halt at step %d", m.Steps())}
+	}
+	if !halted && s.Halted {
+		return &Mismatch{r.next, "halt", fmt.Sprintf("input at step %d", m.Steps())}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package intcode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// echoPlusOne outputs each input plus one until it is given a zero.
+var echoPlusOne = []int{3, 15, 1006, 15, 14, 1001, 15, 1, 15, 4, 15, 1105, 1, 0, 99, 0}
+
+func recordSession(t *testing.T, program []int, inputs ...int) *Session {
+	m := New(program)
+	m.Input = NewQueue(inputs...)
+	rec := Record(m)
+	if err := m.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Session()
+}
+
+func TestSessionRoundTrip(t *testing.T) {
+	s := recordSession(t, echoPlusOne, 41, -7, 0)
+	if !s.Halted || len(s.Events) != 5 || s.Events[1] != (Event{3, OutputEvent, 42}) {
+		t.Fatalf("recorded %+v", s)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteSession(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadSession(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(s) {
+		t.Errorf("read back %+v, want %+v", got, s)
+	}
+
+	for _, e := range Engines {
+		m := New(echoPlusOne)
+		m.SetEngine(e)
+		if err := Replay(m, got); err != nil {
+			t.Errorf("%v: %v", e, err)
+		}
+	}
+}
+
+func TestReplayDiverges(t *testing.T) {
+	s := recordSession(t, echoPlusOne, 41, 0)
+	// Output the input plus two instead.
+	changed := append([]int(nil), echoPlusOne...)
+	changed[7] = 2
+
+	waiting := recordSession(t, echoPlusOne, 41)
+
+	tests := []struct {
+		name    string
+		program []int
+		session *Session
+		want    *Mismatch
+	}{
+		{"output", changed, s, &Mismatch{1, "output 42 at step 3", "output 43 at step 3"}},
+		{"halted early", echoPlusOne, &Session{Checksum: s.Checksum, Events: s.Events[:2], Halted: true},
+			&Mismatch{2, "halt", "input at step 5"}},
+		{"waiting for input", echoPlusOne, waiting, nil},
+	}
+	for _, tt := range tests {
+		// Only the events are under test, so pass the checksum check.
+		session := *tt.session
+		session.Checksum = ProgramChecksum(tt.program)
+		err := Replay(New(tt.program), &session)
+		if tt.want == nil {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		var d *Mismatch
+		if !errors.As(err, &d) || *d != *tt.want || !errors.Is(err, ErrDiverged) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	if err := Replay(New(changed), s); !errors.Is(err, ErrSessionProgram) {
+		t.Errorf("replaying against another program: got %v", err)
+	}
+}
+
+func TestReadSessionErrors(t *testing.T) {
+	tests := []struct {
+		text string
+		line int
+	}{
+		{"0 in 1\n", 1},
+		{"program 0\n0 sideways 1\n", 2},
+		{"program 0\n5 in 1\n2 out 3\n", 3},
+		{"program 0\nend halted\n0 in 1\n", 3},
+	}
+	for _, tt := range tests {
+		_, err := ReadSession(strings.NewReader(tt.text))
+		var perr *ParseError
+		if !errors.As(err, &perr) || perr.Line != tt.line || !errors.Is(err, ErrBadSession) {
+			t.Errorf("%q: got %v, want an error on line %d", tt.text, err, tt.line)
+		}
+	}
+}
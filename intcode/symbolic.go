@@ -0,0 +1,947 @@
+package intcode
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type exprOp int
+
+const (
+	constOp exprOp = iota
+	inputOp
+	addOp
+	mulOp
+	opaqueOp
+)
+
+// Expr is a value computed by a program in terms of the inputs it has read.
+// Input i is the i'th value consumed by opcode 3, counting from after any
+// SymbolicOptions.Unknowns.
+type Expr struct {
+	op   exprOp
+	val  int
+	a, b *Expr
+}
+
+var zeroExpr = constExpr(0)
+
+func constExpr(v int) *Expr {
+	return &Expr{op: constOp, val: v}
+}
+
+func inputExpr(i int) *Expr {
+	return &Expr{op: inputOp, val: i}
+}
+
+// opaqueExpr is a value read through an address that depends on input, when
+// SymbolicOptions.OpaqueReads is set. Each one is distinct.
+func opaqueExpr() *Expr {
+	return &Expr{op: opaqueOp}
+}
+
+// opaque reports whether e depends on a value read through an address that
+// depends on input, so it cannot be evaluated.
+func (e *Expr) opaque() bool {
+	switch e.op {
+	case opaqueOp:
+		return true
+	case addOp, mulOp:
+		return e.a.opaque() || e.b.opaque()
+	}
+	return false
+}
+
+func addExpr(a, b *Expr) *Expr {
+	if av, ok := a.Const(); ok {
+		if bv, ok := b.Const(); ok {
+			return constExpr(av + bv)
+		}
+		if av == 0 {
+			return b
+		}
+	}
+	if bv, ok := b.Const(); ok && bv == 0 {
+		return a
+	}
+	return &Expr{op: addOp, a: a, b: b}
+}
+
+func mulExpr(a, b *Expr) *Expr {
+	if av, ok := a.Const(); ok {
+		if bv, ok := b.Const(); ok {
+			return constExpr(av * bv)
+		}
+		if av == 0 {
+			return zeroExpr
+		}
+		if av == 1 {
+			return b
+		}
+	}
+	if bv, ok := b.Const(); ok {
+		if bv == 0 {
+			return zeroExpr
+		}
+		if bv == 1 {
+			return a
+		}
+	}
+	return &Expr{op: mulOp, a: a, b: b}
+}
+
+// Const returns the value of e if it does not depend on any input.
+func (e *Expr) Const() (int, bool) {
+	return e.val, e.op == constOp
+}
+
+// Eval computes e for the given inputs. Inputs that were not supplied are 0,
+// as are values read through input-dependent addresses.
+func (e *Expr) Eval(inputs []int) int {
+	switch e.op {
+	case constOp, opaqueOp:
+		return e.val
+	case inputOp:
+		if e.val < len(inputs) {
+			return inputs[e.val]
+		}
+		return 0
+	case addOp:
+		return e.a.Eval(inputs) + e.b.Eval(inputs)
+	default:
+		return e.a.Eval(inputs) * e.b.Eval(inputs)
+	}
+}
+
+func (e *Expr) String() string {
+	switch e.op {
+	case constOp:
+		return fmt.Sprint(e.val)
+	case inputOp:
+		return fmt.Sprintf("in%d", e.val)
+	case opaqueOp:
+		return "?"
+	case addOp:
+		return fmt.Sprintf("(%v + %v)", e.a, e.b)
+	default:
+		return fmt.Sprintf("(%v * %v)", e.a, e.b)
+	}
+}
+
+// linear is sum(coeffs[i] * in_i) + c.
+type linear struct {
+	coeffs map[int]int
+	c      int
+}
+
+// linearize rewrites e as a linear combination of inputs if it is one.
+func linearize(e *Expr) (linear, bool) {
+	switch e.op {
+	case constOp:
+		return linear{map[int]int{}, e.val}, true
+	case inputOp:
+		return linear{map[int]int{e.val: 1}, 0}, true
+	case opaqueOp:
+		return linear{}, false
+	}
+	a, ok := linearize(e.a)
+	if !ok {
+		return a, false
+	}
+	b, ok := linearize(e.b)
+	if !ok {
+		return b, false
+	}
+	if e.op == addOp {
+		for i, k := range b.coeffs {
+			a.coeffs[i] += k
+		}
+		a.c += b.c
+		return a, true
+	}
+	if len(a.coeffs) > 0 && len(b.coeffs) > 0 {
+		return a, false
+	}
+	if len(a.coeffs) > 0 {
+		a, b = b, a
+	}
+	for i, k := range b.coeffs {
+		b.coeffs[i] = k * a.c
+	}
+	b.c *= a.c
+	return b, true
+}
+
+// Linear returns e as c plus the sum of coeffs[i] times input i, if it is a
+// linear combination of the inputs.
+func (e *Expr) Linear() (coeffs map[int]int, c int, ok bool) {
+	l, ok := linearize(e)
+	return l.coeffs, l.c, ok
+}
+
+type Relation int
+
+const (
+	Eq Relation = iota
+	Ne
+	Lt
+	Ge
+)
+
+var relationNames = []string{"==", "!=", "<", ">="}
+
+func (r Relation) String() string {
+	if r < 0 || int(r) >= len(relationNames) {
+		return fmt.Sprintf("Relation(%d)", int(r))
+	}
+	return relationNames[r]
+}
+
+// Constraint is a condition on the inputs that must hold for a path to be taken.
+type Constraint struct {
+	Left  *Expr
+	Rel   Relation
+	Right *Expr
+}
+
+func (c Constraint) Holds(inputs []int) bool {
+	l, r := c.Left.Eval(inputs), c.Right.Eval(inputs)
+	switch c.Rel {
+	case Eq:
+		return l == r
+	case Ne:
+		return l != r
+	case Lt:
+		return l < r
+	default:
+		return l >= r
+	}
+}
+
+func (c Constraint) String() string {
+	return fmt.Sprintf("%v %v %v", c.Left, c.Rel, c.Right)
+}
+
+// Branch is one outcome of a conditional instruction whose condition depends
+// on input. For jumps Taken means the jump happened, for comparisons that the
+// result was 1.
+type Branch struct {
+	Ip    int
+	Taken bool
+}
+
+type PathEnd int
+
+const (
+	PathHalted PathEnd = iota
+	PathError
+	PathLimit
+)
+
+var pathEndNames = []string{"halted", "error", "step limit"}
+
+func (p PathEnd) String() string {
+	if p < 0 || int(p) >= len(pathEndNames) {
+		return fmt.Sprintf("PathEnd(%d)", int(p))
+	}
+	return pathEndNames[p]
+}
+
+// Path is one route through a program. Every input satisfying Constraints
+// makes the program take exactly the Branches listed, produce Outputs and
+// leave Memory as it ends.
+type Path struct {
+	Inputs      int
+	Outputs     []*Expr
+	Constraints []Constraint
+	Branches    []Branch
+	Memory      []*Expr
+	End         PathEnd
+	Err         error
+}
+
+// Cell returns the value at addr when p ended.
+func (p Path) Cell(addr int) *Expr {
+	if addr >= 0 && addr < len(p.Memory) && p.Memory[addr] != nil {
+		return p.Memory[addr]
+	}
+	return zeroExpr
+}
+
+var ErrSymbolic = errors.New("value depends on input")
+
+// SymbolicOptions bounds symbolic execution. Inputs are assumed to lie in
+// [MinInput, MaxInput]; a path is abandoned after MaxSteps instructions and
+// exploration stops after MaxPaths paths. SolveBudget caps the number of
+// candidate assignments the solver tries for one set of constraints.
+type SymbolicOptions struct {
+	MinInput    int
+	MaxInput    int
+	MaxSteps    int
+	MaxPaths    int
+	SolveBudget int
+	// Unknowns lists cells whose starting values are inputs too, numbered
+	// before any read by opcode 3, as in day 2 where the noun and verb are
+	// patched into the program.
+	Unknowns []int
+	// OpaqueReads makes a read through an input-dependent address give an
+	// unknown value rather than splitting the path on the address. A path
+	// that branches on, jumps to or writes through such a value fails.
+	OpaqueReads bool
+}
+
+var DefaultSymbolicOptions = SymbolicOptions{
+	MinInput:    -1000,
+	MaxInput:    1000,
+	MaxSteps:    100000,
+	MaxPaths:    1024,
+	SolveBudget: 1000000,
+}
+
+type symbolicState struct {
+	memory []*Expr
+	ip     int
+	bp     int
+	steps  int
+	path   Path
+	done   bool
+	// pins holds the value chosen for a symbolic expression that had to be
+	// made concrete, such as an address or an instruction.
+	pins map[*Expr]int
+}
+
+func (s *symbolicState) fork() *symbolicState {
+	f := *s
+	f.memory = append([]*Expr(nil), s.memory...)
+	f.path.Outputs = append([]*Expr(nil), s.path.Outputs...)
+	f.path.Constraints = append([]Constraint(nil), s.path.Constraints...)
+	f.path.Branches = append([]Branch(nil), s.path.Branches...)
+	f.pins = make(map[*Expr]int, len(s.pins))
+	for e, v := range s.pins {
+		f.pins[e] = v
+	}
+	return &f
+}
+
+func (s *symbolicState) fail(err error) {
+	s.path.End, s.path.Err = PathError, fmt.Errorf("ip %d: %w", s.ip, err)
+	s.done = true
+}
+
+func (s *symbolicState) cell(addr int) *Expr {
+	if addr < len(s.memory) && s.memory[addr] != nil {
+		return s.memory[addr]
+	}
+	return zeroExpr
+}
+
+// instructionEncodings lists every valid instruction value, used to split a
+// path on an instruction that was computed from input.
+var instructionEncodings = func() []int {
+	var encodings []int
+	for _, opcode := range Standard.Opcodes() {
+		in, _ := Standard.Lookup(opcode)
+		nParams := len(in.Roles)
+		combos := 1
+		for p := 0; p < nParams; p++ {
+			combos *= 3
+		}
+		for c := 0; c < combos; c++ {
+			enc, scale := opcode, 100
+			for m := c; m > 0; m, scale = m/3, scale*10 {
+				enc += (m % 3) * scale
+			}
+			encodings = append(encodings, enc)
+		}
+	}
+	sort.Ints(encodings)
+	return encodings
+}()
+
+// concrete returns the value of e when it is fixed on this path. Otherwise it
+// splits the path: with candidates, one fork per feasible candidate; without,
+// a fork pinning e to a value the constraints allow and a fork excluding that
+// value, which is split again when it re-executes the same instruction.
+func (s *symbolicState) concrete(e *Expr, what string, candidates []int, opts SymbolicOptions) (int, []*symbolicState, bool) {
+	if v, ok := e.Const(); ok {
+		return v, nil, true
+	}
+	if v, ok := s.pins[e]; ok {
+		return v, nil, true
+	}
+
+	pin := func(v int) *symbolicState {
+		f := s.fork()
+		f.path.Constraints = append(f.path.Constraints, Constraint{e, Eq, constExpr(v)})
+		f.pins[e] = v
+		return f
+	}
+
+	if e.opaque() {
+		s.fail(fmt.Errorf("%w: %s %v", ErrSymbolic, what, e))
+		return 0, nil, false
+	}
+	inputs, status := solveConstraints(s.path.Constraints, s.path.Inputs, opts)
+	if status == unsat {
+		s.fail(fmt.Errorf("%w: %s %v", ErrSymbolic, what, e))
+		return 0, nil, false
+	}
+	// The constraints may already leave e only one value.
+	if status == sat {
+		v := e.Eval(inputs)
+		others := append(append([]Constraint(nil), s.path.Constraints...), Constraint{e, Ne, constExpr(v)})
+		if _, status := solveConstraints(others, s.path.Inputs, opts); status == unsat {
+			s.pins[e] = v
+			return v, nil, true
+		}
+	}
+
+	var forks []*symbolicState
+	if candidates != nil {
+		for _, c := range candidates {
+			f := pin(c)
+			if _, status := solveConstraints(f.path.Constraints, f.path.Inputs, opts); status != unsat {
+				forks = append(forks, f)
+			}
+		}
+	} else if status == sat {
+		v := e.Eval(inputs)
+		rest := s.fork()
+		rest.path.Constraints = append(rest.path.Constraints, Constraint{e, Ne, constExpr(v)})
+		forks = append(forks, pin(v), rest)
+	}
+	if len(forks) == 0 {
+		s.fail(fmt.Errorf("%w: %s %v", ErrSymbolic, what, e))
+	}
+	return 0, forks, false
+}
+
+// Explore symbolically executes program, treating every value read by
+// opcode 3 as an unknown. It returns a path for each feasible combination of
+// branches, forking at opcodes 5 to 8 whenever the condition depends on input.
+// Instructions, addresses and jump targets computed from input are split into
+// a path for each value they can take.
+func Explore(program []int, opts SymbolicOptions) []Path {
+	start := &symbolicState{pins: map[*Expr]int{}}
+	for _, v := range program {
+		start.memory = append(start.memory, constExpr(v))
+	}
+	for i, addr := range opts.Unknowns {
+		start.setVal(Parameter{addr, positionMode}, addr, inputExpr(i))
+	}
+	start.path.Inputs = len(opts.Unknowns)
+
+	var paths []Path
+	stack := []*symbolicState{start}
+	for len(stack) > 0 && len(paths) < opts.MaxPaths {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		for !s.done {
+			forked := s.step(opts)
+			if forked == nil {
+				continue
+			}
+			// Push in reverse so the first fork is explored first.
+			for i := len(forked) - 1; i >= 0; i-- {
+				f := forked[i]
+				if _, status := solveConstraints(f.path.Constraints, f.path.Inputs, opts); status == unsat {
+					log.WithFields(log.Fields{"ip": f.ip, "constraints": f.path.Constraints}).Debug("Pruned infeasible path")
+					continue
+				}
+				stack = append(stack, f)
+			}
+			break
+		}
+		if s.done {
+			s.path.Memory = s.memory
+			paths = append(paths, s.path)
+		}
+	}
+	return paths
+}
+
+// step executes one instruction. When the instruction cannot be executed
+// without knowing more about the inputs, s is abandoned and the paths that
+// replace it are returned instead.
+func (s *symbolicState) step(opts SymbolicOptions) []*symbolicState {
+	if s.steps >= opts.MaxSteps {
+		s.path.End, s.done = PathLimit, true
+		return nil
+	}
+
+	instruction, forks, ok := s.concrete(s.cell(s.ip), "instruction", instructionEncodings, opts)
+	if !ok {
+		return forks
+	}
+	op, err := parseOp(instruction)
+	if err != nil {
+		s.fail(err)
+		return nil
+	}
+	if op.opcode == 99 {
+		s.path.End, s.done = PathHalted, true
+		return nil
+	}
+
+	// Resolve every address up front so that nothing has changed if the
+	// path has to be split.
+	addrs := make([]int, op.nParams)
+	vals := make([]*Expr, op.nParams)
+	for i, p := range op.params {
+		raw := s.cell(s.ip + 1 + i)
+		if p.mode == immediateMode {
+			vals[i] = raw
+			continue
+		}
+		if _, pinned := s.pins[raw]; opts.OpaqueReads && op.instruction.Roles[i] == Read && !pinned {
+			if _, ok := raw.Const(); !ok {
+				vals[i] = opaqueExpr()
+				continue
+			}
+		}
+		addr, forks, ok := s.concrete(raw, "address", nil, opts)
+		if !ok {
+			return forks
+		}
+		if p.mode == relativeMode {
+			addr += s.bp
+		}
+		if addr < 0 {
+			s.fail(fmt.Errorf("negative address %d", addr))
+			return nil
+		}
+		addrs[i], vals[i] = addr, s.cell(addr)
+	}
+
+	var target, adjust int
+	if op.opcode == 5 || op.opcode == 6 {
+		if target, forks, ok = s.concrete(vals[1], "jump target", nil, opts); !ok {
+			return forks
+		}
+	} else if op.opcode == 9 {
+		if adjust, forks, ok = s.concrete(vals[0], "relative base adjustment", nil, opts); !ok {
+			return forks
+		}
+	}
+
+	ip := s.ip
+	s.steps++
+	s.ip += op.nParams + 1
+
+	switch op.opcode {
+	case 1:
+		err = s.setVal(op.params[2], addrs[2], addExpr(vals[0], vals[1]))
+	case 2:
+		err = s.setVal(op.params[2], addrs[2], mulExpr(vals[0], vals[1]))
+	case 3:
+		err = s.setVal(op.params[0], addrs[0], inputExpr(s.path.Inputs))
+		s.path.Inputs++
+	case 4:
+		s.path.Outputs = append(s.path.Outputs, vals[0])
+	case 5:
+		return s.branch(ip, Constraint{vals[0], Ne, zeroExpr}, Constraint{vals[0], Eq, zeroExpr}, func(t *symbolicState) error {
+			t.ip = target
+			return nil
+		}, nil)
+	case 6:
+		return s.branch(ip, Constraint{vals[0], Eq, zeroExpr}, Constraint{vals[0], Ne, zeroExpr}, func(t *symbolicState) error {
+			t.ip = target
+			return nil
+		}, nil)
+	case 7, 8:
+		taken, notTaken := Constraint{vals[0], Lt, vals[1]}, Constraint{vals[0], Ge, vals[1]}
+		if op.opcode == 8 {
+			taken, notTaken = Constraint{vals[0], Eq, vals[1]}, Constraint{vals[0], Ne, vals[1]}
+		}
+		return s.branch(ip, taken, notTaken, func(t *symbolicState) error {
+			return t.setVal(op.params[2], addrs[2], constExpr(1))
+		}, func(t *symbolicState) error {
+			return t.setVal(op.params[2], addrs[2], constExpr(0))
+		})
+	case 9:
+		s.bp += adjust
+	}
+	if err != nil {
+		s.ip = ip
+		s.fail(err)
+	}
+	return nil
+}
+
+func (s *symbolicState) setVal(param Parameter, addr int, val *Expr) error {
+	if param.mode == immediateMode {
+		return fmt.Errorf("write to immediate mode parameter")
+	}
+	for addr >= len(s.memory) {
+		s.memory = append(s.memory, make([]*Expr, addr-len(s.memory)+1)...)
+	}
+	s.memory[addr] = val
+	return nil
+}
+
+// branch completes a conditional instruction at ip. If the condition is
+// decided by constants it continues in place, otherwise s is split into a fork
+// for each outcome and the input-dependent decision is recorded on each.
+func (s *symbolicState) branch(ip int, taken, notTaken Constraint, onTaken, onNotTaken func(*symbolicState) error) []*symbolicState {
+	apply := func(t *symbolicState, isTaken bool) {
+		f := onNotTaken
+		if isTaken {
+			f = onTaken
+		}
+		if f == nil {
+			return
+		}
+		if err := f(t); err != nil {
+			t.ip = ip
+			t.fail(err)
+		}
+	}
+
+	if taken.Left.opaque() || taken.Right.opaque() {
+		s.ip = ip
+		s.fail(fmt.Errorf("%w: condition %v", ErrSymbolic, taken))
+		return nil
+	}
+	_, lok := taken.Left.Const()
+	_, rok := taken.Right.Const()
+	if lok && rok {
+		apply(s, taken.Holds(nil))
+		return nil
+	}
+
+	var forks []*symbolicState
+	for _, side := range []struct {
+		c     Constraint
+		taken bool
+	}{{taken, true}, {notTaken, false}} {
+		f := s.fork()
+		f.path.Constraints = append(f.path.Constraints, side.c)
+		f.path.Branches = append(f.path.Branches, Branch{ip, side.taken})
+		apply(f, side.taken)
+		forks = append(forks, f)
+	}
+	return forks
+}
+
+type solveStatus int
+
+const (
+	sat solveStatus = iota
+	unsat
+	unknown
+)
+
+// narrow tightens the range of an input using c if c is linear in that input
+// alone. It returns false if c cannot be satisfied.
+func (b *inputBounds) narrow(c Constraint) bool {
+	l, ok := linearize(addExpr(c.Left, mulExpr(constExpr(-1), c.Right)))
+	if !ok {
+		return true
+	}
+	vars := 0
+	v, a := 0, 0
+	for i, k := range l.coeffs {
+		if k != 0 {
+			vars++
+			v, a = i, k
+		}
+	}
+	if vars == 0 {
+		return c.Holds(nil)
+	}
+	if vars > 1 || v >= len(b.lo) {
+		return true
+	}
+
+	// a*x + l.c rel 0
+	lo, hi := b.lo[v], b.hi[v]
+	switch c.Rel {
+	case Eq:
+		if l.c%a != 0 {
+			return false
+		}
+		lo, hi = raise(lo, -l.c/a), lower(hi, -l.c/a)
+	case Lt:
+		// a*x <= -c-1
+		if a > 0 {
+			hi = lower(hi, FloorDiv(-l.c-1, a))
+		} else {
+			lo = raise(lo, CeilDiv(-l.c-1, a))
+		}
+	case Ge:
+		// a*x >= -c
+		if a > 0 {
+			lo = raise(lo, CeilDiv(-l.c, a))
+		} else {
+			hi = lower(hi, FloorDiv(-l.c, a))
+		}
+	}
+	b.lo[v], b.hi[v] = lo, hi
+	return lo <= hi
+}
+
+type inputBounds struct {
+	lo, hi []int
+}
+
+// solveConstraints looks for values of the first n inputs satisfying every
+// constraint. Linear constraints on a single input narrow its range first,
+// then the remaining candidates are tried smallest magnitude first.
+func solveConstraints(cs []Constraint, n int, opts SymbolicOptions) ([]int, solveStatus) {
+	for _, c := range cs {
+		if c.Left.opaque() || c.Right.opaque() {
+			return nil, unknown
+		}
+	}
+	b := &inputBounds{make([]int, n), make([]int, n)}
+	for i := range b.lo {
+		b.lo[i], b.hi[i] = opts.MinInput, opts.MaxInput
+	}
+	for _, c := range cs {
+		if !b.narrow(c) {
+			return nil, unsat
+		}
+	}
+
+	// Check each constraint as soon as the last input it mentions is assigned.
+	checks := make([][]Constraint, n+1)
+	for _, c := range cs {
+		last := 0
+		for _, e := range []*Expr{c.Left, c.Right} {
+			if m := lastInput(e) + 1; m > last {
+				last = m
+			}
+		}
+		if last > n {
+			last = n
+		}
+		checks[last] = append(checks[last], c)
+	}
+	for _, c := range checks[0] {
+		if !c.Holds(nil) {
+			return nil, unsat
+		}
+	}
+
+	inputs := make([]int, n)
+	budget := opts.SolveBudget
+	var assign func(i int) solveStatus
+	assign = func(i int) solveStatus {
+		if i == n {
+			return sat
+		}
+		status := unsat
+		for _, v := range candidates(b.lo[i], b.hi[i]) {
+			if budget <= 0 {
+				return unknown
+			}
+			budget--
+			inputs[i] = v
+			ok := true
+			for _, c := range checks[i+1] {
+				if !c.Holds(inputs[:i+1]) {
+					ok = false
+					break
+				}
+			}
+			if !ok {
+				continue
+			}
+			switch assign(i + 1) {
+			case sat:
+				return sat
+			case unknown:
+				status = unknown
+			}
+		}
+		return status
+	}
+
+	status := assign(0)
+	if status != sat {
+		return nil, status
+	}
+	return inputs, sat
+}
+
+// candidates lists [lo, hi] ordered by distance from zero.
+func candidates(lo, hi int) []int {
+	var vals []int
+	start := 0
+	if start < lo {
+		start = lo
+	} else if start > hi {
+		start = hi
+	}
+	vals = append(vals, start)
+	for d := 1; start-d >= lo || start+d <= hi; d++ {
+		if start+d <= hi {
+			vals = append(vals, start+d)
+		}
+		if start-d >= lo {
+			vals = append(vals, start-d)
+		}
+	}
+	return vals
+}
+
+func lastInput(e *Expr) int {
+	switch e.op {
+	case constOp, opaqueOp:
+		return -1
+	case inputOp:
+		return e.val
+	}
+	a, b := lastInput(e.a), lastInput(e.b)
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func raise(lo, x int) int {
+	if x > lo {
+		return x
+	}
+	return lo
+}
+
+func lower(hi, x int) int {
+	if x < hi {
+		return x
+	}
+	return hi
+}
+
+// FloorDiv returns a/b rounded towards negative infinity.
+func FloorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+// CeilDiv returns a/b rounded towards positive infinity.
+func CeilDiv(a, b int) int {
+	return -FloorDiv(-a, b)
+}
+
+// Solve returns inputs that drive the program down p, if any exist within
+// the input range of opts.
+func (p Path) Solve(opts SymbolicOptions) ([]int, bool) {
+	inputs, status := solveConstraints(p.Constraints, p.Inputs, opts)
+	return inputs, status == sat
+}
+
+var ErrNoInputs = errors.New("no inputs found")
+
+// InputsForOutput finds inputs for which the last value program outputs
+// before halting is value. Of the paths that can produce it, the inputs with
+// the smallest total magnitude are returned.
+func InputsForOutput(program []int, value int, opts SymbolicOptions) ([]int, error) {
+	var best []int
+	bestSize := 0
+	for _, p := range Explore(program, opts) {
+		if p.End != PathHalted || len(p.Outputs) == 0 {
+			continue
+		}
+		p.Constraints = append(p.Constraints, Constraint{p.Outputs[len(p.Outputs)-1], Eq, constExpr(value)})
+		inputs, ok := p.Solve(opts)
+		if !ok {
+			continue
+		}
+		size := 0
+		for _, v := range inputs {
+			if v < 0 {
+				v = -v
+			}
+			size += v
+		}
+		if best == nil || size < bestSize {
+			best, bestSize = inputs, size
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("%w: for output %d", ErrNoInputs, value)
+	}
+	return best, nil
+}
+
+// Coverage is a set of test inputs exercising the branches of a program.
+// Uncovered lists the branch outcomes seen while exploring that none of the
+// inputs reach, either because they are infeasible or could not be solved.
+type Coverage struct {
+	Inputs    [][]int
+	Covered   []Branch
+	Uncovered []Branch
+}
+
+// CoverBranches picks paths until both outcomes of every reachable
+// conditional are exercised and solves each for a concrete set of inputs.
+func CoverBranches(program []int, opts SymbolicOptions) Coverage {
+	paths := Explore(program, opts)
+
+	seen := map[Branch]bool{}
+	for _, p := range paths {
+		for _, b := range p.Branches {
+			seen[b] = true
+			seen[Branch{b.Ip, !b.Taken}] = true
+		}
+	}
+
+	covered := map[Branch]bool{}
+	var cov Coverage
+	for {
+		best, bestNew := -1, 0
+		for i, p := range paths {
+			n := 0
+			for _, b := range p.Branches {
+				if !covered[b] {
+					n++
+				}
+			}
+			if n > bestNew {
+				best, bestNew = i, n
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		p := paths[best]
+		paths = append(paths[:best], paths[best+1:]...)
+		inputs, ok := p.Solve(opts)
+		if !ok {
+			continue
+		}
+		cov.Inputs = append(cov.Inputs, inputs)
+		for _, b := range p.Branches {
+			covered[b] = true
+		}
+	}
+
+	for b := range seen {
+		if covered[b] {
+			cov.Covered = append(cov.Covered, b)
+		} else {
+			cov.Uncovered = append(cov.Uncovered, b)
+		}
+	}
+	sortBranches(cov.Covered)
+	sortBranches(cov.Uncovered)
+	return cov
+}
+
+func sortBranches(bs []Branch) {
+	sort.Slice(bs, func(i, j int) bool {
+		if bs[i].Ip != bs[j].Ip {
+			return bs[i].Ip < bs[j].Ip
+		}
+		return !bs[i].Taken && bs[j].Taken
+	})
+}
@@ -0,0 +1,157 @@
+package intcode
+
+import (
+	"fmt"
+	"testing"
+)
+
+// smallInputs keeps the input-dependent address and opcode splits short.
+var smallInputs = SymbolicOptions{MinInput: 0, MaxInput: 200, MaxSteps: 1000, MaxPaths: 1024, SolveBudget: 100000}
+
+func TestInputsForOutput(t *testing.T) {
+	// The input is the instruction at 2, which must be an immediate output of
+	// the 7 after it. Any jump it could be lands on the halt at 99 rather than
+	// looping back to read more input.
+	inputOpcode := make([]int, 100)
+	copy(inputOpcode, []int{3, 2, 0, 7, 99})
+	inputOpcode[99] = 99
+
+	tests := []struct {
+		name    string
+		program []int
+		opts    SymbolicOptions
+		output  int
+		want    []int
+	}{
+		{"equal to 8", []int{3, 9, 8, 9, 10, 9, 4, 9, 99, -1, 8}, DefaultSymbolicOptions, 1, []int{8}},
+		{"not equal to 8", []int{3, 9, 8, 9, 10, 9, 4, 9, 99, -1, 8}, DefaultSymbolicOptions, 0, []int{0}},
+		{"less than 8", []int{3, 9, 7, 9, 10, 9, 4, 9, 99, -1, 8}, DefaultSymbolicOptions, 1, []int{0}},
+		{"not less than 8", []int{3, 9, 7, 9, 10, 9, 4, 9, 99, -1, 8}, DefaultSymbolicOptions, 0, []int{8}},
+		{"jump non-zero", []int{3, 12, 6, 12, 15, 1, 13, 14, 13, 4, 13, 99, -1, 0, 1, 9}, DefaultSymbolicOptions, 1, []int{1}},
+		{"above 8", compare8, DefaultSymbolicOptions, 1001, []int{9}},
+		{"linear", []int{3, 9, 1002, 9, 3, 9, 4, 9, 99, 0}, DefaultSymbolicOptions, 42, []int{14}},
+		// The input is the address of the value output.
+		{"input address", []int{3, 3, 4, 0, 99, 7}, smallInputs, 7, []int{5}},
+		{"input opcode", inputOpcode, smallInputs, 7, []int{104}},
+	}
+	for _, tt := range tests {
+		got, err := InputsForOutput(tt.program, tt.output, tt.opts)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: inputs = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := InputsForOutput([]int{3, 9, 8, 9, 10, 9, 4, 9, 99, -1, 8}, 2, DefaultSymbolicOptions); err == nil {
+		t.Error("found inputs for an output the program cannot produce")
+	}
+}
+
+func TestExplore(t *testing.T) {
+	// The equals at 9 only runs when in0 < 5, so its taken side can never
+	// happen and is pruned.
+	unsat := []int{3, 20, 7, 20, 21, 22, 1006, 22, 14, 8, 20, 23, 22, 99, 99, 0, 0, 0, 0, 0, 0, 5, 0, 8}
+
+	tests := []struct {
+		name    string
+		program []int
+		opts    SymbolicOptions
+		want    []string
+	}{
+		{"straight line", []int{104, 5, 99}, DefaultSymbolicOptions, []string{"halted [5] [] []"}},
+		{"equals", []int{3, 9, 8, 9, 10, 9, 4, 9, 99, -1, 8}, DefaultSymbolicOptions, []string{
+			"halted [1] [in0 == 8] [{2 true}]",
+			"halted [0] [in0 != 8] [{2 false}]",
+		}},
+		{"jump", []int{3, 3, 1105, -1, 9, 1101, 0, 0, 12, 4, 12, 99, 1}, DefaultSymbolicOptions, []string{
+			"halted [1] [in0 != 0] [{2 true}]",
+			"halted [0] [in0 == 0] [{2 false}]",
+		}},
+		{"unsat pruned", unsat, DefaultSymbolicOptions, []string{
+			"halted [] [in0 < 5 in0 != 8] [{2 true} {9 false}]",
+			"halted [] [in0 >= 5] [{2 false}]",
+		}},
+		{"max steps", []int{1105, 1, 0}, DefaultSymbolicOptions, []string{"step limit [] [] []"}},
+		{"max paths", []int{3, 3, 4, 0, 99, 7}, SymbolicOptions{MinInput: 0, MaxInput: 200, MaxSteps: 1000, MaxPaths: 3, SolveBudget: 100000}, []string{
+			"halted [3] [in0 == 0] []",
+			"halted [3] [in0 != 0 in0 == 1] []",
+			"halted [4] [in0 != 0 in0 != 1 in0 == 2] []",
+		}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, p := range Explore(tt.program, tt.opts) {
+			got = append(got, fmt.Sprintf("%v %v %v %v", p.End, p.Outputs, p.Constraints, p.Branches))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: paths =\n%v\nwant\n%v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCoverBranches(t *testing.T) {
+	cov := CoverBranches(compare8, DefaultSymbolicOptions)
+	if len(cov.Uncovered) != 0 {
+		t.Errorf("uncovered = %v", cov.Uncovered)
+	}
+
+	// Every set of inputs must drive the concrete machine to one of the
+	// three answers.
+	seen := map[int]bool{}
+	for _, inputs := range cov.Inputs {
+		m := New(compare8)
+		out := &Queue{}
+		m.Input, m.Output = NewQueue(inputs...), out
+		if err := m.Run(); err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range out.Values() {
+			seen[v] = true
+		}
+	}
+	if !seen[999] || !seen[1000] || !seen[1001] {
+		t.Errorf("outputs seen = %v, want 999, 1000 and 1001", seen)
+	}
+}
+
+func TestExploreUnknowns(t *testing.T) {
+	opts := DefaultSymbolicOptions
+	opts.Unknowns = []int{1, 2}
+	opts.OpaqueReads = true
+
+	// The first add reads through the unknowns as addresses, the second
+	// adds them.
+	paths := Explore([]int{1, 0, 0, 3, 1, 1, 2, 0, 99}, opts)
+	if len(paths) != 1 || paths[0].End != PathHalted {
+		t.Fatalf("paths = %v", paths)
+	}
+	if got := fmt.Sprint(paths[0].Cell(0), paths[0].Cell(3)); got != "(in0 + in1) (? + ?)" {
+		t.Errorf("cells 0 and 3 = %s", got)
+	}
+	if coeffs, c, ok := paths[0].Cell(0).Linear(); !ok || c != 0 || coeffs[0] != 1 || coeffs[1] != 1 {
+		t.Errorf("linear = %v, %d, %t", coeffs, c, ok)
+	}
+
+	// Branching on a value read through them cannot be followed.
+	paths = Explore([]int{1, 0, 0, 7, 1005, 7, 9, 0, 99, 99}, opts)
+	if len(paths) != 1 || paths[0].End != PathError {
+		t.Errorf("paths = %v, want one that fails", paths)
+	}
+}
+
+func TestSymbolicNames(t *testing.T) {
+	for _, c := range []struct {
+		got  fmt.Stringer
+		want string
+	}{
+		{Ge, ">="},
+		{Relation(4), "Relation(4)"},
+		{PathLimit, "step limit"},
+		{PathEnd(-1), "PathEnd(-1)"},
+	} {
+		if c.got.String() != c.want {
+			t.Errorf("String() = %q, want %q", c.got.String(), c.want)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package intcode
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+)
+
+// CellKind is how a memory cell is highlighted by a Visualizer. When more
+// than one applies, the first in this list wins.
+type CellKind int
+
+const (
+	PlainCell CellKind = iota
+	// CurrentCell is part of the instruction at ip.
+	CurrentCell
+	// WrittenCell was written within the last Recent steps.
+	WrittenCell
+	// RelativeCell is within RelativeWindow cells of the relative base, once
+	// the program has moved it.
+	RelativeCell
+	// ExecutedCell is part of an instruction that has been executed.
+	ExecutedCell
+)
+
+var cellKindNames = []string{"plain", "ip", "written", "relative", "executed"}
+
+func (k CellKind) String() string {
+	if k < 0 || int(k) >= len(cellKindNames) {
+		return fmt.Sprintf("CellKind(%d)", int(k))
+	}
+	return cellKindNames[k]
+}
+
+// Visualizer is an Observer that tracks which cells a machine executes and
+// writes, and draws its memory as a grid with them highlighted.
+type Visualizer struct {
+	// Width is the number of cells in each row.
+	Width int
+	// Recent is how many steps a written cell stays highlighted for.
+	Recent int
+	// RelativeWindow is how many cells from the relative base on are
+	// highlighted.
+	RelativeWindow int
+
+	executed map[int]bool
+	relative bool
+	// written holds the step count at which each cell was last written.
+	written map[int]int
+}
+
+func NewVisualizer() *Visualizer {
+	return &Visualizer{
+		Width:          10,
+		Recent:         20,
+		RelativeWindow: 8,
+		executed:       map[int]bool{},
+		written:        map[int]int{},
+	}
+}
+
+// instructionLength returns how many cells the instruction at ip covers, 1 if
+// it is not a valid instruction.
+func instructionLength(m *Machine, ip int) int {
+	if ip < 0 || ip >= m.mem.size {
+		return 1
+	}
+	op, err := m.InstructionSet().decode(m.mem.get(ip))
+	if err != nil {
+		return 1
+	}
+	return op.nParams + 1
+}
+
+func (v *Visualizer) BeforeStep(m *Machine) {
+	for addr, n := m.ip, instructionLength(m, m.ip); n > 0; addr, n = addr+1, n-1 {
+		v.executed[addr] = true
+	}
+}
+
+func (v *Visualizer) AfterStep(m *Machine, ip int, err error) {
+	v.relative = v.relative || m.bp != 0
+}
+
+func (v *Visualizer) OnRead(m *Machine, addr int, val int) {}
+
+func (v *Visualizer) OnWrite(m *Machine, addr int, val int) {
+	v.written[addr] = m.Steps()
+}
+
+func (v *Visualizer) OnWait(m *Machine) {}
+
+func (v *Visualizer) OnHalt(m *Machine) {}
+
+// Kind returns how the cell at addr is highlighted.
+func (v *Visualizer) Kind(m *Machine, addr int) CellKind {
+	if addr >= m.ip && addr < m.ip+instructionLength(m, m.ip) && m.status != Halted {
+		return CurrentCell
+	}
+	if step, ok := v.written[addr]; ok && m.Steps()-step < v.Recent {
+		return WrittenCell
+	}
+	if v.relative && addr >= m.bp && addr < m.bp+v.RelativeWindow {
+		return RelativeCell
+	}
+	if v.executed[addr] {
+		return ExecutedCell
+	}
+	return PlainCell
+}
+
+// memoryCell returns the value in memory at addr without going through any
+// device mapped there, as loading from a device can change it.
+func memoryCell(m *Machine, addr int) int {
+	return m.mem.get(addr)
+}
+
+// rows calls row for each row of m's memory worth showing, with the address
+// it starts at, or with -1 in place of a run of rows that are all plain zeros.
+func (v *Visualizer) rows(m *Machine, row func(start int)) {
+	size := m.mem.size
+	if end := m.bp + v.RelativeWindow; v.relative && end > size {
+		size = end
+	}
+	skipping := false
+	for start := 0; start < size; start += v.Width {
+		blank := true
+		for addr := start; addr < start+v.Width && blank; addr++ {
+			val := memoryCell(m, addr)
+			blank = val == 0 && v.Kind(m, addr) == PlainCell
+		}
+		if blank {
+			if !skipping {
+				row(-1)
+			}
+			skipping = true
+			continue
+		}
+		skipping = false
+		row(start)
+	}
+}
+
+func (v *Visualizer) header(m *Machine) string {
+	return fmt.Sprintf("ip %d  bp %d  steps %d  %v", m.ip, m.bp, m.Steps(), m.status)
+}
+
+var terminalStyles = map[CellKind]string{
+	CurrentCell:  "\x1b[7m",
+	WrittenCell:  "\x1b[1;31m",
+	RelativeCell: "\x1b[34m",
+	ExecutedCell: "\x1b[32m",
+}
+
+// Render draws m's memory for a terminal, using ANSI escapes for the
+// highlights.
+func (v *Visualizer) Render(w io.Writer, m *Machine) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, v.header(m))
+	v.rows(m, func(start int) {
+		if start < 0 {
+			fmt.Fprintln(bw, "     ...")
+			return
+		}
+		fmt.Fprintf(bw, "%6d:", start)
+		for addr := start; addr < start+v.Width; addr++ {
+			val := memoryCell(m, addr)
+			if style, ok := terminalStyles[v.Kind(m, addr)]; ok {
+				fmt.Fprintf(bw, " %s%6d\x1b[0m", style, val)
+			} else {
+				fmt.Fprintf(bw, " %6d", val)
+			}
+		}
+		fmt.Fprintln(bw)
+	})
+	for _, k := range []CellKind{CurrentCell, WrittenCell, RelativeCell, ExecutedCell} {
+		fmt.Fprintf(bw, "%s%s\x1b[0m ", terminalStyles[k], k)
+	}
+	fmt.Fprintln(bw)
+	return bw.Flush()
+}
+
+const htmlStyle = `body { font-family: monospace; }
+td { padding: 0 0.5em; text-align: right; }
+.ip { background: #000; color: #fff; }
+.written { color: #c00; font-weight: bold; }
+.relative { color: #00c; }
+.executed { color: #080; }`
+
+// RenderHTML writes m's memory as a standalone HTML page. If refresh is more
+// than zero the page reloads itself every refresh seconds, so that it can be
+// left open while it is rewritten.
+func (v *Visualizer) RenderHTML(w io.Writer, m *Machine, refresh int) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">")
+	if refresh > 0 {
+		fmt.Fprintf(bw, "<meta http-equiv=\"refresh\" content=\"%d\">\n", refresh)
+	}
+	fmt.Fprintf(bw, "<title>Intcode memory</title>\n<style>\n%s\n</style>\n</head>\n<body>\n", htmlStyle)
+	fmt.Fprintf(bw, "<p>%s</p>\n<table>\n", html.EscapeString(v.header(m)))
+	v.rows(m, func(start int) {
+		if start < 0 {
+			fmt.Fprintln(bw, "<tr><td>...</td></tr>")
+			return
+		}
+		fmt.Fprintf(bw, "<tr><th>%d</th>", start)
+		for addr := start; addr < start+v.Width; addr++ {
+			val := memoryCell(m, addr)
+			if k := v.Kind(m, addr); k != PlainCell {
+				fmt.Fprintf(bw, "<td class=\"%s\">%d</td>", k, val)
+			} else {
+				fmt.Fprintf(bw, "<td>%d</td>", val)
+			}
+		}
+		fmt.Fprintln(bw, "</tr>")
+	})
+	fmt.Fprint(bw, "</table>\n<p>")
+	for _, k := range []CellKind{CurrentCell, WrittenCell, RelativeCell, ExecutedCell} {
+		fmt.Fprintf(bw, "<span class=\"%s\">%s</span> ", k, k)
+	}
+	fmt.Fprintln(bw, "</p>\n</body>\n</html>")
+	return bw.Flush()
+}
@@ -0,0 +1,39 @@
+package intcode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestVisualizer(t *testing.T) {
+	m := New(echoPlusOne)
+	m.Input = NewQueue(41)
+	v := NewVisualizer()
+	v.Width = 4
+	m.Observe(v)
+	if err := m.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Waiting on the input at 0 again, having written 42 to 15.
+	kinds := map[int]CellKind{0: CurrentCell, 1: CurrentCell, 2: ExecutedCell, 14: PlainCell, 15: WrittenCell}
+	for addr, want := range kinds {
+		if got := v.Kind(m, addr); got != want {
+			t.Errorf("cell %d is %v, want %v", addr, got, want)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := v.RenderHTML(&buf, m, 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`<th>12</th><td class="executed">1</td><td class="executed">0</td><td>99</td><td class="written">42</td>`, "ip 0  bp 0  steps 5  waiting for input"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("page is missing %s:\n%s", want, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "refresh") {
+		t.Error("page refreshes itself")
+	}
+}